@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/actions"
+	"p-monitor/pkg/alerting"
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/forecast"
+	"p-monitor/pkg/monitor"
+)
+
+// startAlerting evaluates the alert rules on every collection and performs
+// the alert actions in the background until ctx is cancelled. Replayed
+// collections neither perform actions nor touch the alert journal.
+func startAlerting(ctx context.Context, cfg *config.Config, m *monitor.Monitor) *alerting.Engine {
+	if m.Replaying() {
+		logs.Info("Alert actions and the alert journal are disabled while replaying")
+		engine := alerting.NewEphemeral(cfg)
+		go engine.Run(ctx, m)
+		return engine
+	}
+
+	engine := alerting.New(cfg)
+	go actions.New(engine, cfg).Run(ctx)
+	go engine.Run(ctx, m)
+	return engine
+}
+
+// startForecast forecasts when the disk and memory run full after every
+// collection in the background until ctx is cancelled
+func startForecast(ctx context.Context, cfg *config.Config, m *monitor.Monitor) *forecast.Forecaster {
+	forecaster := forecast.New(m, cfg)
+	go forecaster.Run(ctx)
+	return forecaster
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"p-monitor/pkg/bar"
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/monitor"
+)
+
+// runBar streams metrics to a status bar on stdout, returning the exit code:
+// 0 when the bar stops reading or on SIGINT/SIGTERM, 1 on errors and 2 on usage errors
+func runBar(cfg *config.Config, args []string) int {
+	flags := flag.NewFlagSet("bar", flag.ContinueOnError)
+	protocol := flags.String("protocol", bar.ProtocolWaybar, "output protocol: waybar, i3bar or plain")
+	format := flags.String("format", "", "text template such as \"CPU {cpu} RAM {mem}\", defaults to bar_format")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	m := monitor.New(cfg)
+	b, err := bar.New(m, cfg, *protocol, *format, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// Writes to stdout kill the process with SIGPIPE once the bar has gone
+	// away, ignoring it makes them fail with EPIPE instead
+	signal.Ignore(syscall.SIGPIPE)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go m.Start()
+	defer m.Stop()
+
+	if err := b.Run(ctx); err != nil {
+		// A closed pipe means the bar exited, which is not an error for us
+		if ctx.Err() != nil || errors.Is(err, syscall.EPIPE) {
+			return 0
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
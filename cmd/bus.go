@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/alerting"
+	"p-monitor/pkg/bus"
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/forecast"
+	"p-monitor/pkg/monitor"
+	"p-monitor/pkg/notify"
+)
+
+// startBus serves the D-Bus interface in the background until ctx is cancelled.
+// Sessions without a session bus, such as system services, only log the failure.
+func startBus(ctx context.Context, cfg *config.Config, m *monitor.Monitor) {
+	go func() {
+		if err := bus.New(m, cfg).Serve(ctx); err != nil {
+			logs.Error("D-Bus service stopped: %v", err)
+		}
+	}()
+}
+
+// startNotifications shows desktop notifications of alert transitions and
+// forecasts in the background until ctx is cancelled. openHistory backs the
+// "Open history" button, left out when nil.
+func startNotifications(ctx context.Context, cfg *config.Config, engine *alerting.Engine, forecaster *forecast.Forecaster, openHistory func()) {
+	go func() {
+		if err := notify.New(engine, forecaster, cfg, openHistory).Serve(ctx); err != nil {
+			logs.Error("Desktop notifications stopped: %v", err)
+		}
+	}()
+}
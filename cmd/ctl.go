@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/control"
+	"p-monitor/pkg/monitor"
+)
+
+// ctlUsage describes the commands of p-monitor ctl
+const ctlUsage = `Usage: p-monitor ctl <command> [args]
+
+Commands:
+  status                               Show the state of the running instance
+  interval <value> [seconds|minutes]   Change the update interval
+  temperature [celsius|fahrenheit]     Set or toggle the temperature unit
+  pause                                Pause scheduled collections
+  resume                               Resume scheduled collections
+  refresh                              Collect metrics immediately
+  reload                               Reload the configuration file
+  rotate-logs                          Continue logging to a new log file
+`
+
+// startControl serves the control socket in the background until ctx is cancelled
+func startControl(ctx context.Context, cfg *config.Config, m *monitor.Monitor) {
+	go func() {
+		if err := control.New(m, cfg).Serve(ctx, control.SocketPath()); err != nil {
+			logs.Error("Control socket stopped: %v", err)
+		}
+	}()
+}
+
+// runCtl sends a command to the running instance, returning the exit code:
+// 0 on success, 1 when the command failed and 2 on usage errors
+func runCtl(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(os.Stderr, ctlUsage)
+		return 2
+	}
+
+	resp, err := control.Send(control.SocketPath(), control.Request{Command: args[0], Args: args[1:]})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !resp.OK {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", resp.Error)
+		return 1
+	}
+
+	fmt.Println(resp.Message)
+	return 0
+}
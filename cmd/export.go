@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/export"
+	"p-monitor/pkg/store"
+)
+
+// runExport writes the on-disk history to stdout or a file, returning the
+// exit code: 0 on success, 1 on errors and 2 on usage errors
+func runExport(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	fromFlag := flags.String("from", "1h", "start as an RFC 3339 time or a duration ago such as 30m or 48h")
+	toFlag := flags.String("to", "", "end as an RFC 3339 time or a duration ago, defaults to now")
+	metrics := flags.String("metrics", "", "comma-separated metric paths or prefixes such as cpu,memory.used_percent, defaults to all")
+	format := flags.String("format", export.FormatCSV, "output format: csv or jsonl")
+	output := flags.String("output", "-", "output file, - for stdout")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	now := time.Now()
+	from, err := parseExportTime(*fromFlag, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --from: %v\n", err)
+		return 2
+	}
+	to, err := parseExportTime(*toFlag, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --to: %v\n", err)
+		return 2
+	}
+	if *format != export.FormatCSV && *format != export.FormatJSONL {
+		fmt.Fprintf(os.Stderr, "invalid --format %q, expected csv or jsonl\n", *format)
+		return 2
+	}
+
+	records, err := store.ReadRange(config.DataDir(), from, to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read history: %v\n", err)
+		return 1
+	}
+
+	out := os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create output file: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if err := export.Write(out, *format, store.Samples(records), export.ParseSelectors(*metrics)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// parseExportTime parses an RFC 3339 time or a duration before now. An empty
+// value is the zero time, leaving that end of the range open.
+func parseExportTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a duration", value)
+	}
+	return t, nil
+}
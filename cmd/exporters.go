@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/api"
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/exporter"
+	"p-monitor/pkg/monitor"
+)
+
+// startExporters starts the network exporters enabled in the configuration.
+// They run in the background until ctx is cancelled.
+func startExporters(ctx context.Context, cfg *config.Config, m *monitor.Monitor) {
+	current := cfg.Snapshot()
+
+	if current.MetricsAddress != "" {
+		go func() {
+			if err := exporter.New(m).Serve(ctx, current.MetricsAddress); err != nil {
+				logs.Error("OpenMetrics exporter stopped: %v", err)
+			}
+		}()
+	}
+
+	if current.APIAddress != "" {
+		go func() {
+			if err := api.New(m, cfg).Serve(ctx, current.APIAddress); err != nil {
+				logs.Error("API server stopped: %v", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/monitor"
+	"p-monitor/pkg/output"
+)
+
+// runHeadless runs the monitor without a desktop session until SIGINT or SIGTERM,
+// feeding every collection to the configured non-GUI outputs
+func runHeadless(cfg *config.Config, m *monitor.Monitor) {
+	logs.Info("Starting in headless mode")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	updates := m.Subscribe()
+	defer m.Unsubscribe(updates)
+
+	var fileWriter *output.FileWriter
+	if outputFile := cfg.Snapshot().OutputFile; outputFile != "" {
+		fileWriter = output.NewFileWriter(outputFile)
+		logs.Info("Writing metrics to %s", outputFile)
+	} else {
+		logs.Info("No output file configured, metrics are only collected")
+	}
+
+	startExporters(ctx, cfg, m)
+	startControl(ctx, cfg, m)
+	startBus(ctx, cfg, m)
+	startStore(ctx, cfg, m)
+	alerts := startAlerting(ctx, cfg, m)
+	startNotifications(ctx, cfg, alerts, startForecast(ctx, cfg, m), nil)
+
+	go m.Start()
+	defer m.Stop()
+
+	for {
+		select {
+		case metrics := <-updates:
+			if fileWriter == nil {
+				continue
+			}
+			if err := fileWriter.Write(metrics); err != nil {
+				logs.Error("Failed to write metrics file: %v", err)
+			}
+		case <-ctx.Done():
+			logs.Info("Stopping headless mode")
+			return
+		}
+	}
+}
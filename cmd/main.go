@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"os"
 	"path/filepath"
@@ -15,6 +17,31 @@ import (
 )
 
 func main() {
+	// Subcommands print to the terminal, so they don't log to stdout
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "snapshot":
+			os.Exit(runSnapshot(loadConfig(), os.Args[2:]))
+		case "top":
+			os.Exit(runTop(loadConfig()))
+		case "bar":
+			os.Exit(runBar(loadConfig(), os.Args[2:]))
+		case "export":
+			os.Exit(runExport(os.Args[2:]))
+		case "ctl":
+			os.Exit(runCtl(os.Args[2:]))
+		}
+	}
+
+	headless := flag.Bool("headless", false, "run without the system tray, feeding only non-GUI outputs")
+	record := flag.String("record", "", "record every collection to a file for --replay")
+	replayPath := flag.String("replay", "", "emit the collections recorded in a file instead of reading the machine")
+	replaySpeed := flag.Float64("replay-speed", 1, "replay speed factor, e.g. 10 to replay ten times faster")
+	flag.Parse()
+	if err := checkRecordPath(*record, *replayPath); err != nil {
+		log.Fatal(err)
+	}
+
 	// Initialize logging
 	logDir := filepath.Join(os.Getenv("HOME"), ".p-monitor", "logs")
 	if err := logs.Init(logDir); err != nil {
@@ -22,31 +49,65 @@ func main() {
 	}
 
 	// Load configuration
+	cfg := loadConfig()
+
+	// Initialize monitor
+	m, err := newMonitor(cfg, *replayPath, *replaySpeed)
+	if err != nil {
+		log.Fatalf("Failed to start replay: %v", err)
+	}
+	if *record != "" {
+		startRecorder(context.Background(), m, *record)
+	}
+
+	if *headless {
+		runHeadless(cfg, m)
+		return
+	}
+
+	runDesktop(cfg, m)
+}
+
+// loadConfig loads the configuration, falling back to the defaults without
+// ever saving them over the file that failed to load
+func loadConfig() *config.Config {
 	cfg, err := config.Load()
 	if err != nil {
-		logs.Error("Failed to load configuration: %v", err)
-		cfg = config.Default()
+		logs.Error("Failed to load configuration, running with the defaults until it is fixed and reloaded: %v", err)
+		return config.Fallback(err)
 	}
+	return cfg
+}
 
+// runDesktop runs the monitor with the system tray display
+func runDesktop(cfg *config.Config, monitor *monitor.Monitor) {
 	// Create Fyne application
 	a := app.NewWithID("com.p-monitor.app")
 	a.SetIcon(nil) // We'll set the system tray icon instead
 
 	// Check if we're running on desktop
 	if desk, ok := a.(desktop.App); ok {
-		// Initialize monitor
-		monitor := monitor.New(cfg)
-
 		// Initialize display
 		display := display.New(desk, monitor, cfg)
 
+		// Start the exporters enabled in the configuration
+		startExporters(context.Background(), cfg, monitor)
+		startControl(context.Background(), cfg, monitor)
+		startBus(context.Background(), cfg, monitor)
+		display.SetStore(startStore(context.Background(), cfg, monitor))
+		alerts := startAlerting(context.Background(), cfg, monitor)
+		display.SetAlerts(alerts)
+		forecaster := startForecast(context.Background(), cfg, monitor)
+		display.SetForecaster(forecaster)
+		startNotifications(context.Background(), cfg, alerts, forecaster, display.ShowHistory)
+
 		// Start monitoring
 		go monitor.Start()
 
 		// Start display
 		display.Start()
 	} else {
-		logs.Error("This application requires desktop environment")
+		logs.Error("This application requires desktop environment, use --headless to run without it")
 		os.Exit(1)
 	}
 
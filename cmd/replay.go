@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/monitor"
+	"p-monitor/pkg/replay"
+)
+
+// newMonitor creates the monitor, emitting the recording at replayPath
+// instead of reading the machine when it is set
+func newMonitor(cfg *config.Config, replayPath string, speed float64) (*monitor.Monitor, error) {
+	m := monitor.New(cfg)
+	if replayPath == "" {
+		return m, nil
+	}
+
+	player, err := replay.Open(replayPath)
+	if err != nil {
+		return nil, err
+	}
+	m.SetReplay(player, speed)
+	logs.Info("Replaying metrics from %s", replayPath)
+
+	return m, nil
+}
+
+// checkRecordPath fails when recordPath names the recording being replayed,
+// which creating the recording would truncate
+func checkRecordPath(recordPath, replayPath string) error {
+	if recordPath == "" || replayPath == "" {
+		return nil
+	}
+
+	recordAbs, recordErr := filepath.Abs(recordPath)
+	replayAbs, replayErr := filepath.Abs(replayPath)
+	same := recordErr == nil && replayErr == nil && recordAbs == replayAbs
+	if recordInfo, err := os.Stat(recordPath); err == nil {
+		if replayInfo, err := os.Stat(replayPath); err == nil {
+			same = same || os.SameFile(recordInfo, replayInfo)
+		}
+	}
+	if same {
+		return fmt.Errorf("--record and --replay name the same file %s", replayPath)
+	}
+	return nil
+}
+
+// startRecorder writes every collection to the recording at path in the
+// background until ctx is cancelled
+func startRecorder(ctx context.Context, m *monitor.Monitor, path string) {
+	recorder, err := replay.Create(path)
+	if err != nil {
+		logs.Error("Failed to start recording: %v", err)
+		return
+	}
+	logs.Info("Recording metrics to %s", path)
+
+	updates := m.Subscribe()
+	go func() {
+		defer recorder.Close()
+		defer m.Unsubscribe(updates)
+
+		for {
+			select {
+			case metrics, ok := <-updates:
+				if !ok {
+					return
+				}
+				if err := recorder.Write(metrics); err != nil {
+					logs.Error("Failed to record metrics: %v", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/monitor"
+	"p-monitor/pkg/types"
+)
+
+// runSnapshot performs a single collection and prints it, returning the exit
+// code: 0 on success, 1 when any collector failed and 2 on usage errors
+func runSnapshot(cfg *config.Config, args []string) int {
+	flags := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	format := flags.String("format", "text", "output format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown format %q, expected text or json\n", *format)
+		return 2
+	}
+
+	m := monitor.New(cfg)
+	metrics := m.Collect()
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(metrics); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to encode metrics: %v\n", err)
+			return 1
+		}
+	case "text":
+		printSnapshotTable(os.Stdout, metrics, cfg.Snapshot().TemperatureUnit)
+	}
+
+	failed := false
+	for _, collector := range metrics.Collectors {
+		if collector.Error != "" {
+			fmt.Fprintf(os.Stderr, "Collector %s failed: %s\n", collector.Name, collector.Error)
+			failed = true
+		}
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// printSnapshotTable prints metrics as a human readable table, with
+// temperatures in the given unit
+func printSnapshotTable(out io.Writer, metrics *types.SystemMetrics, temperatureUnit string) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "METRIC\tVALUE")
+
+	if disk := metrics.Disk; disk != nil {
+		if disk.Error != "" {
+			fmt.Fprintln(w, "HDD\tn/a")
+		} else {
+			fmt.Fprintf(w, "HDD\t%s / %s (%.1f%%)\n", types.FormatGigabytes(disk.Used), types.FormatGigabytes(disk.Total), disk.UsedPercent)
+		}
+	}
+
+	for _, mount := range metrics.Mounts {
+		if mount.Error != "" {
+			fmt.Fprintf(w, "HDD %s\tn/a\n", mount.Mount)
+		} else {
+			fmt.Fprintf(w, "HDD %s\t%s / %s (%.1f%%)\n", mount.Mount, types.FormatGigabytes(mount.Used), types.FormatGigabytes(mount.Total), mount.UsedPercent)
+		}
+	}
+
+	for _, drive := range metrics.Drives {
+		// Errors take precedence, as warnings are only derived from read data
+		status := "ok"
+		switch {
+		case drive.Error != "":
+			status = drive.Error
+		case len(drive.Warnings) > 0:
+			status = fmt.Sprintf("%d warnings", len(drive.Warnings))
+		}
+		fmt.Fprintf(w, "Drive %s\t%s, %s\n", drive.Device, types.FormatReading(drive.Temperature, temperatureUnit, 1), status)
+	}
+
+	if memory := metrics.Memory; memory != nil {
+		if memory.Error != "" {
+			fmt.Fprintln(w, "RAM\tn/a")
+		} else {
+			fmt.Fprintf(w, "RAM\t%s / %s (%.1f%%)\n", types.FormatGigabytes(memory.Used), types.FormatGigabytes(memory.Total), memory.UsedPercent)
+		}
+	}
+
+	if cpu := metrics.CPU; cpu != nil {
+		if cpu.Error != "" {
+			fmt.Fprintln(w, "CPU\tn/a")
+		} else {
+			fmt.Fprintf(w, "CPU\t%.1f%% (%s)\n", cpu.UsagePercent, types.FormatReading(cpu.Temperature, temperatureUnit, 1))
+		}
+	}
+
+	for _, gpu := range metrics.GPUs {
+		if gpu.Error != "" {
+			fmt.Fprintf(w, "%s\tn/a\n", gpu.Name)
+		} else {
+			fmt.Fprintf(w, "%s\t%.1f%% (%s)\n", gpu.Name, gpu.UsagePercent, types.FormatReading(gpu.Temperature, temperatureUnit, 1))
+		}
+	}
+
+	if network := metrics.Network; network != nil {
+		// A single collection has no rates, so show the totals
+		if network.Error != "" {
+			fmt.Fprintln(w, "Network\tn/a")
+		} else {
+			fmt.Fprintf(w, "Network\tRX %s, TX %s\n", types.FormatGigabytes(network.RxBytes), types.FormatGigabytes(network.TxBytes))
+		}
+	}
+
+	for _, service := range metrics.Services {
+		fmt.Fprintf(w, "Service %s\t%.1f%% CPU, %s\n", service.Name, service.CPUPercent, types.FormatGigabytes(service.MemoryBytes))
+	}
+
+	for _, slice := range metrics.Slices {
+		fmt.Fprintf(w, "Slice %s\t%.1f%% CPU, %s\n", slice.Name, slice.CPUPercent, types.FormatGigabytes(slice.MemoryBytes))
+	}
+
+	for _, container := range metrics.Containers {
+		fmt.Fprintf(w, "Container %s\t%.1f%% CPU, %s\n", container.Name, container.CPUPercent, types.FormatGigabytes(container.MemoryBytes))
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/monitor"
+	"p-monitor/pkg/store"
+)
+
+// startStore opens the on-disk history and appends every collection to it in
+// the background until ctx is cancelled. It returns nil when the store is
+// disabled or cannot be opened, and while replaying a recording so that
+// replayed collections don't mix with the machine's history.
+func startStore(ctx context.Context, cfg *config.Config, m *monitor.Monitor) *store.Store {
+	maxSizeMB := cfg.Snapshot().DataMaxSizeMB
+	if maxSizeMB == 0 || m.Replaying() {
+		return nil
+	}
+
+	s, err := store.Open(config.DataDir(), int64(maxSizeMB)*1024*1024)
+	if err != nil {
+		logs.Error("Failed to open metrics store: %v", err)
+		return nil
+	}
+	logs.Info("Storing metrics history in %s", config.DataDir())
+
+	updates := m.Subscribe()
+	go func() {
+		defer s.Close()
+		defer m.Unsubscribe(updates)
+
+		for {
+			select {
+			case metrics, ok := <-updates:
+				if !ok {
+					return
+				}
+				if err := s.Append(metrics); err != nil {
+					logs.Error("Failed to store metrics: %v", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return s
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/monitor"
+	"p-monitor/pkg/tui"
+)
+
+// runTop runs the full-screen terminal dashboard, returning the exit code
+func runTop(cfg *config.Config) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	m := monitor.New(cfg)
+	go m.Start()
+	defer m.Stop()
+
+	if err := tui.New(m, cfg).Run(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
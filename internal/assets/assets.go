@@ -0,0 +1,53 @@
+package assets
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	dirOnce sync.Once
+	dir     string
+)
+
+// Path returns the absolute path of an icon of the assets directory, which is
+// found relative to the executable rather than the working directory, as
+// notification servers and autostarted sessions don't share ours
+func Path(name string) string {
+	dirOnce.Do(func() {
+		dir = findDir()
+	})
+	return filepath.Join(dir, name)
+}
+
+// findDir returns the first assets directory found next to the executable, as
+// in a development build, in the share directory of an installed package
+// (/usr/share/p-monitor/assets for /usr/bin/p-monitor), or in the working
+// directory as a last resort
+func findDir() string {
+	var candidates []string
+	if exe, err := os.Executable(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+		exeDir := filepath.Dir(exe)
+		candidates = append(candidates,
+			filepath.Join(exeDir, "assets"),
+			filepath.Join(exeDir, "..", "share", "p-monitor", "assets"))
+	}
+	if wd, err := os.Getwd(); err == nil {
+		candidates = append(candidates, filepath.Join(wd, "assets"))
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return filepath.Clean(candidate)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return "assets"
+	}
+	return candidates[len(candidates)-1]
+}
@@ -2,41 +2,83 @@ package logs
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
 var (
+	mu      sync.Mutex
 	logger  *log.Logger
 	logFile *os.File
+	logDir  string
 )
 
 // Init initializes the logging system
-func Init(logDir string) error {
+func Init(dir string) error {
 	// Create log directory if it doesn't exist
-	if err := os.MkdirAll(logDir, 0755); err != nil {
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create log directory: %v", err)
 	}
 
+	mu.Lock()
+	defer mu.Unlock()
+
+	logDir = dir
+	logPath, err := openLogFile()
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("[INFO] Logging initialized at %s", logPath)
+	return nil
+}
+
+// Rotate closes the current log file and continues logging to a new one
+func Rotate() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if logger == nil {
+		return fmt.Errorf("logging is not initialized")
+	}
+
+	previous := logFile
+	logPath, err := openLogFile()
+	if err != nil {
+		return err
+	}
+	previous.Close()
+
+	logger.Printf("[INFO] Log rotated to %s", logPath)
+	return nil
+}
+
+// openLogFile opens a new timestamped log file in logDir and directs the logger to it
+func openLogFile() (string, error) {
 	// Create log file with timestamp
 	timestamp := time.Now().Format("2006-01-02_15-04-05")
 	logPath := filepath.Join(logDir, fmt.Sprintf("p-monitor_%s.log", timestamp))
 
 	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to create log file: %v", err)
+		return "", fmt.Errorf("failed to create log file: %v", err)
 	}
 
-	logFile = file
-	logger = log.New(file, "", log.LstdFlags|log.Lshortfile)
-
 	// Also log to stdout for debugging
-	logger.SetOutput(os.Stdout)
+	output := io.MultiWriter(file, os.Stdout)
 
-	Info("Logging initialized at %s", logPath)
-	return nil
+	logFile = file
+	if logger == nil {
+		logger = log.New(output, "", log.LstdFlags|log.Lshortfile)
+	} else {
+		logger.SetOutput(output)
+	}
+
+	return logPath, nil
 }
 
 // Info logs an info message
@@ -0,0 +1,282 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/alerting"
+	"p-monitor/pkg/config"
+)
+
+const (
+	// retryDelay is the wait before the first webhook retry, doubled on every further one
+	retryDelay = time.Second
+
+	// maxOutputLog limits how much of a failed command's output is logged
+	maxOutputLog = 512
+
+	// maxRunning limits how many transitions a single action handles at once
+	maxRunning = 4
+
+	// waitDelay is how long a command's output is waited for after it is
+	// killed, as children it started may hold it open
+	waitDelay = time.Second
+)
+
+// Runner performs the configured alert actions on alert transitions
+type Runner struct {
+	engine *alerting.Engine
+	config *config.Config
+	client *http.Client
+
+	mu      sync.Mutex
+	running map[string]int  // Transitions in progress by action name
+	pending map[string]bool // Transitions in progress by action name, alert path and state
+}
+
+// New creates an action runner reading its actions from cfg, so that
+// configuration changes apply to the next transitions
+func New(engine *alerting.Engine, cfg *config.Config) *Runner {
+	return &Runner{
+		engine:  engine,
+		config:  cfg,
+		client:  &http.Client{},
+		running: make(map[string]int),
+		pending: make(map[string]bool),
+	}
+}
+
+// Run performs the actions matching every alert transition until ctx is
+// cancelled. Actions run asynchronously, so a slow webhook doesn't hold
+// back the others, but a transition is skipped while the same action still
+// handles it or already handles maxRunning others.
+func (r *Runner) Run(ctx context.Context) {
+	events := r.engine.Subscribe()
+	defer r.engine.Unsubscribe(events)
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			for _, action := range r.config.Snapshot().AlertActions {
+				if !matches(action, event) {
+					continue
+				}
+				key, ok := r.start(action, event)
+				if !ok {
+					continue
+				}
+				go func() {
+					defer r.finish(action, key)
+					r.perform(ctx, action, event)
+				}()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// matches reports whether an action applies to a transition. Pending alerts
+// trigger no action.
+func matches(action config.AlertAction, event alerting.Event) bool {
+	if event.Alert.State == alerting.StatePending {
+		return false
+	}
+	if len(action.On) > 0 && !slices.Contains(action.On, string(event.Alert.State)) {
+		return false
+	}
+	return len(action.Rules) == 0 || slices.Contains(action.Rules, event.Alert.Rule)
+}
+
+// start reserves a slot for an action to handle a transition, returning the
+// key to release with finish, or false when the transition is skipped
+func (r *Runner) start(action config.AlertAction, event alerting.Event) (string, bool) {
+	key := action.Name + "\x00" + event.Alert.Path + "\x00" + string(event.Alert.State)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending[key] {
+		logs.Debug("Alert action %s already handles %s alert %s", action.Name, event.Alert.State, event.Alert.Rule)
+		return "", false
+	}
+	if r.running[action.Name] >= maxRunning {
+		logs.Error("Skipping alert action %s for %s alert %s: %d transitions are still in progress",
+			action.Name, event.Alert.State, event.Alert.Rule, maxRunning)
+		return "", false
+	}
+
+	r.pending[key] = true
+	r.running[action.Name]++
+	return key, true
+}
+
+// finish releases the slot reserved by start
+func (r *Runner) finish(action config.AlertAction, key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.pending, key)
+	if r.running[action.Name]--; r.running[action.Name] == 0 {
+		delete(r.running, action.Name)
+	}
+}
+
+// perform runs the webhook and command of an action, logging the outcome
+func (r *Runner) perform(ctx context.Context, action config.AlertAction, event alerting.Event) {
+	timeout, err := action.TimeoutDuration()
+	if err != nil {
+		logs.Error("Skipping alert action %s: %v", action.Name, err)
+		return
+	}
+
+	if action.WebhookURL != "" {
+		if err := r.sendWebhook(ctx, action, event, timeout); err != nil {
+			logs.Error("Alert action %s webhook failed: %v", action.Name, err)
+		} else {
+			logs.Info("Alert action %s posted %s alert %s", action.Name, event.Alert.State, event.Alert.Rule)
+		}
+	}
+
+	if len(action.Command) > 0 {
+		if err := runCommand(ctx, action, event, timeout); err != nil {
+			logs.Error("Alert action %s command failed: %v", action.Name, err)
+		} else {
+			logs.Info("Alert action %s ran %s for %s alert %s", action.Name, action.Command[0], event.Alert.State, event.Alert.Rule)
+		}
+	}
+}
+
+// sendWebhook posts the payload of an event, retrying on network errors,
+// 429 and 5xx responses with an exponential backoff
+func (r *Runner) sendWebhook(ctx context.Context, action config.AlertAction, event alerting.Event, timeout time.Duration) error {
+	payload, err := renderPayload(action, event)
+	if err != nil {
+		return err
+	}
+
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := r.post(ctx, action.WebhookURL, payload, timeout)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= action.Retries {
+			return err
+		}
+
+		logs.Debug("Alert action %s webhook attempt %d failed, retrying in %s: %v", action.Name, attempt+1, delay, err)
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// post sends a single webhook request, reporting whether a failure is worth retrying
+func (r *Runner) post(ctx context.Context, url string, payload []byte, timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "p-monitor")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook answered %s", resp.Status)
+}
+
+// renderPayload returns the JSON payload of an event, rendered from the
+// action's template or the event itself
+func renderPayload(action config.AlertAction, event alerting.Event) ([]byte, error) {
+	tmpl, err := action.ParseTemplate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse webhook template: %v", err)
+	}
+	if tmpl == nil {
+		return json.Marshal(event)
+	}
+
+	var payload bytes.Buffer
+	if err := tmpl.Execute(&payload, event); err != nil {
+		return nil, fmt.Errorf("failed to render webhook template: %v", err)
+	}
+	if !json.Valid(payload.Bytes()) {
+		return nil, fmt.Errorf("webhook template did not render valid JSON: %s", payload.String())
+	}
+	return payload.Bytes(), nil
+}
+
+// runCommand runs the command of an action with the alert in its environment
+func runCommand(ctx context.Context, action config.AlertAction, event alerting.Event, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, action.Command[0], action.Command[1:]...)
+	cmd.Env = append(os.Environ(), environment(event)...)
+	cmd.WaitDelay = waitDelay
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		text := strings.TrimSpace(string(output))
+		if len(text) > maxOutputLog {
+			text = text[:maxOutputLog] + "..."
+		}
+		if text != "" {
+			return fmt.Errorf("%v: %s", err, text)
+		}
+		return err
+	}
+	return nil
+}
+
+// environment returns the PMONITOR_ALERT_* variables describing an event
+func environment(event alerting.Event) []string {
+	alert := event.Alert
+	vars := []string{
+		"PMONITOR_ALERT_RULE=" + alert.Rule,
+		"PMONITOR_ALERT_PATH=" + alert.Path,
+		"PMONITOR_ALERT_STATE=" + string(alert.State),
+		"PMONITOR_ALERT_PREVIOUS_STATE=" + string(event.Previous),
+		"PMONITOR_ALERT_SEVERITY=" + alert.Severity,
+		"PMONITOR_ALERT_VALUE=" + strconv.FormatFloat(alert.Value, 'f', -1, 64),
+		"PMONITOR_ALERT_COMPARISON=" + alert.Comparison,
+		"PMONITOR_ALERT_THRESHOLD=" + strconv.FormatFloat(alert.Threshold, 'f', -1, 64),
+		"PMONITOR_ALERT_SINCE=" + alert.Since.Format(time.RFC3339),
+	}
+	if !alert.FiredAt.IsZero() {
+		vars = append(vars, "PMONITOR_ALERT_FIRED_AT="+alert.FiredAt.Format(time.RFC3339))
+	}
+	if !alert.ResolvedAt.IsZero() {
+		vars = append(vars, "PMONITOR_ALERT_RESOLVED_AT="+alert.ResolvedAt.Format(time.RFC3339))
+	}
+	return vars
+}
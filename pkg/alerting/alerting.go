@@ -0,0 +1,453 @@
+package alerting
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/monitor"
+	"p-monitor/pkg/types"
+)
+
+// State is the state of an alert
+type State string
+
+// Alert states. An alert is pending while its condition holds for less than
+// the rule's for duration, firing afterwards and resolved once its value
+// clears. Pending alerts whose condition stops holding are dropped silently.
+const (
+	StatePending  State = "pending"
+	StateFiring   State = "firing"
+	StateResolved State = "resolved"
+)
+
+// SnoozeDuration is how long the "Snooze 1h" actions of the tray and of
+// notifications silence an alert
+const SnoozeDuration = time.Hour
+
+// Alert is a rule applied to one metric path
+type Alert struct {
+	Rule       string    `json:"rule"`
+	Path       string    `json:"path"`
+	Severity   string    `json:"severity"`
+	State      State     `json:"state"`
+	Value      float64   `json:"value"` // Latest value of the metric
+	Comparison string    `json:"comparison"`
+	Threshold  float64   `json:"threshold"`
+	Since      time.Time `json:"since"`                 // When the condition started holding
+	FiredAt    time.Time `json:"fired_at,omitempty"`    // Zero while pending
+	ResolvedAt time.Time `json:"resolved_at,omitempty"` // Zero until resolved
+
+	Acknowledged bool      `json:"acknowledged,omitempty"`
+	SnoozedUntil time.Time `json:"snoozed_until,omitempty"` // Zero unless snoozed
+}
+
+// Event is a state transition of an alert
+type Event struct {
+	Alert    Alert `json:"alert"`
+	Previous State `json:"previous,omitempty"` // Empty for new alerts
+}
+
+// Engine evaluates the configured alert rules on every collection. The latest
+// resolved alerts and the acknowledgements and snoozes are kept in a journal
+// file across restarts. It is safe for concurrent use.
+type Engine struct {
+	config      *config.Config
+	mu          sync.RWMutex
+	active      map[string]*Alert    // Pending and firing alerts, keyed by rule and path
+	missing     map[string]time.Time // When the path of a firing alert disappeared
+	subscribers map[chan Event]struct{}
+	invalid     map[string]bool // Rules already reported as invalid
+	configured  []config.AlertRule
+	compiled    []*rule // Compiled from configured, the rules of the latest evaluation
+	journal     *journal
+	journalPath string // Empty when the journal is not persisted
+}
+
+// New creates an alerting engine reading its rules from cfg, so that
+// configuration changes apply on the next evaluation
+func New(cfg *config.Config) *Engine {
+	path := config.AlertJournalPath()
+	j, err := loadJournal(path)
+	if err != nil {
+		logs.Error("Starting with an empty alert journal: %v", err)
+	}
+
+	return newEngine(cfg, j, path)
+}
+
+// NewEphemeral creates an alerting engine like New that starts with an empty
+// journal and never writes it, for collections that are not live
+func NewEphemeral(cfg *config.Config) *Engine {
+	return newEngine(cfg, &journal{}, "")
+}
+
+// newEngine creates an alerting engine keeping its journal in path
+func newEngine(cfg *config.Config, j *journal, path string) *Engine {
+	return &Engine{
+		config:      cfg,
+		active:      make(map[string]*Alert),
+		missing:     make(map[string]time.Time),
+		subscribers: make(map[chan Event]struct{}),
+		invalid:     make(map[string]bool),
+		journal:     j,
+		journalPath: path,
+	}
+}
+
+// Run evaluates every collection of the monitor until ctx is cancelled
+func (e *Engine) Run(ctx context.Context, m *monitor.Monitor) {
+	updates := m.Subscribe()
+	defer m.Unsubscribe(updates)
+
+	for {
+		select {
+		case metrics, ok := <-updates:
+			if !ok {
+				return
+			}
+			e.Evaluate(metrics)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Evaluate applies the rules to a collection, returning the resulting
+// transitions, which are also sent to the subscribers
+func (e *Engine) Evaluate(metrics *types.SystemMetrics) []Event {
+	cfg := e.config.Snapshot()
+	values := metrics.Values()
+	now := metrics.Updated
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rules := e.rules(cfg.AlertRules)
+	var events []Event
+	seen := make(map[string]bool)
+	changed := e.journal.expire(time.Now())
+
+	for _, r := range rules {
+		for path, value := range values {
+			if !r.matches(path) {
+				continue
+			}
+			key := r.Name + "\x00" + path
+			seen[key] = true
+			delete(e.missing, key)
+
+			if event, ok := e.step(key, r, path, value, now); ok {
+				events = append(events, event)
+			}
+		}
+	}
+
+	failed := make(map[string]bool)
+	for _, collector := range metrics.Collectors {
+		if collector.Error != "" {
+			failed[collector.Name] = true
+		}
+	}
+
+	// Firing alerts whose path disappeared, e.g. a stopped container, resolve
+	// once it has been missing for the rule's for duration, but are kept while
+	// their collector fails. Alerts of removed rules resolve right away and
+	// pending ones are dropped.
+	for key, alert := range e.active {
+		if seen[key] {
+			continue
+		}
+
+		i := slices.IndexFunc(rules, func(r *rule) bool { return r.Name == alert.Rule })
+		if i >= 0 && alert.State == StateFiring {
+			if failed[collectorOf(alert.Path)] {
+				continue
+			}
+			since, ok := e.missing[key]
+			if !ok {
+				since = now
+				e.missing[key] = now
+			}
+			if now.Sub(since) < rules[i].hold {
+				continue
+			}
+		}
+
+		delete(e.active, key)
+		delete(e.missing, key)
+		if alert.State == StateFiring {
+			events = append(events, e.resolve(alert, now))
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Alert.Rule != events[j].Alert.Rule {
+			return events[i].Alert.Rule < events[j].Alert.Rule
+		}
+		return events[i].Alert.Path < events[j].Alert.Path
+	})
+
+	// Resolved alerts enter the journal and lose their acknowledgement
+	for i, event := range events {
+		if event.Alert.State != StateResolved {
+			continue
+		}
+		events[i].Alert.Acknowledged = false
+		e.journal.Acknowledged, _ = unset(e.journal.Acknowledged, event.Alert.Rule, event.Alert.Path)
+		e.journal.addResolved(events[i].Alert, cfg.AlertHistorySize)
+		changed = true
+	}
+	if changed {
+		e.saveJournal()
+	}
+
+	for i := range events {
+		e.decorate(&events[i].Alert)
+	}
+	for _, event := range events {
+		logs.Info("Alert %s on %s is %s (value %g, threshold %s %g)",
+			event.Alert.Rule, event.Alert.Path, event.Alert.State,
+			event.Alert.Value, event.Alert.Comparison, event.Alert.Threshold)
+		e.notify(event)
+	}
+
+	return events
+}
+
+// step advances the alert of a rule and path with a new value. e.mu must be held.
+func (e *Engine) step(key string, r *rule, path string, value float64, now time.Time) (Event, bool) {
+	alert, ok := e.active[key]
+	if !ok {
+		if !r.breached(value) {
+			return Event{}, false
+		}
+
+		alert = &Alert{
+			Rule:       r.Name,
+			Path:       path,
+			Severity:   r.Severity,
+			State:      StatePending,
+			Value:      value,
+			Comparison: r.Comparison,
+			Threshold:  r.Threshold,
+			Since:      now,
+		}
+		e.active[key] = alert
+
+		if r.hold > 0 {
+			return Event{Alert: *alert}, true
+		}
+		alert.State = StateFiring
+		alert.FiredAt = now
+		return Event{Alert: *alert}, true
+	}
+
+	alert.Value = value
+	alert.Severity = r.Severity
+	alert.Comparison = r.Comparison
+	alert.Threshold = r.Threshold
+
+	switch alert.State {
+	case StatePending:
+		if !r.breached(value) {
+			delete(e.active, key)
+			return Event{}, false
+		}
+		if now.Sub(alert.Since) >= r.hold {
+			alert.State = StateFiring
+			alert.FiredAt = now
+			return Event{Alert: *alert, Previous: StatePending}, true
+		}
+	case StateFiring:
+		if r.cleared(value) {
+			delete(e.active, key)
+			return e.resolve(alert, now), true
+		}
+	}
+	return Event{}, false
+}
+
+// resolve marks a firing alert resolved, returning the transition
+func (e *Engine) resolve(alert *Alert, now time.Time) Event {
+	alert.State = StateResolved
+	alert.ResolvedAt = now
+	return Event{Alert: *alert, Previous: StateFiring}
+}
+
+// rules returns the compiled rules, only compiling them again when the
+// configured rules changed and logging each invalid rule once. e.mu must be held.
+func (e *Engine) rules(configured []config.AlertRule) []*rule {
+	if e.compiled != nil && slices.EqualFunc(configured, e.configured, equalRules) {
+		return e.compiled
+	}
+
+	rules := make([]*rule, 0, len(configured))
+	for _, r := range configured {
+		compiled, err := compileRule(r)
+		if err != nil {
+			if !e.invalid[r.Name] {
+				e.invalid[r.Name] = true
+				logs.Error("Ignoring alert rule: %v", err)
+			}
+			continue
+		}
+		rules = append(rules, compiled)
+	}
+
+	e.configured = configured
+	e.compiled = rules
+	return rules
+}
+
+// equalRules reports whether two configured rules are the same
+func equalRules(a, b config.AlertRule) bool {
+	if (a.Clear == nil) != (b.Clear == nil) || (a.Clear != nil && *a.Clear != *b.Clear) {
+		return false
+	}
+	a.Clear, b.Clear = nil, nil
+	return a == b
+}
+
+// collectorOf returns the name of the collector producing a metric path
+func collectorOf(path string) string {
+	name := path
+	if i := strings.IndexAny(path, ".["); i >= 0 {
+		name = path[:i]
+	}
+
+	switch name {
+	case "mounts":
+		return "disk"
+	case "services", "slices", "containers":
+		return "cgroups"
+	}
+	return name
+}
+
+// Alerts returns the pending and firing alerts, sorted by rule and path
+func (e *Engine) Alerts() []Alert {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	alerts := make([]Alert, 0, len(e.active))
+	for _, alert := range e.active {
+		decorated := *alert
+		e.decorate(&decorated)
+		alerts = append(alerts, decorated)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Rule != alerts[j].Rule {
+			return alerts[i].Rule < alerts[j].Rule
+		}
+		return alerts[i].Path < alerts[j].Path
+	})
+	return alerts
+}
+
+// Resolved returns the latest resolved alerts, newest first
+func (e *Engine) Resolved() []Alert {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return append([]Alert(nil), e.journal.Resolved...)
+}
+
+// Acknowledge marks the alert of a rule and path as seen until it resolves,
+// silencing its notifications
+func (e *Engine) Acknowledge(rule, path string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.journal.Acknowledged = set(e.journal.Acknowledged, mark{Rule: rule, Path: path})
+	logs.Info("Alert %s on %s acknowledged", rule, path)
+	return e.saveJournal()
+}
+
+// Snooze silences the notifications of the alert of a rule and path for d
+func (e *Engine) Snooze(rule, path string, d time.Duration) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.journal.Snoozed = set(e.journal.Snoozed, mark{Rule: rule, Path: path, Until: time.Now().Add(d)})
+	logs.Info("Alert %s on %s snoozed for %s", rule, path, d)
+	return e.saveJournal()
+}
+
+// Muted reports whether the notifications of an alert are silenced, because
+// it was acknowledged or is snoozed
+func (e *Engine) Muted(rule, path string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	alert := Alert{Rule: rule, Path: path}
+	e.decorate(&alert)
+	return alert.Acknowledged || !alert.SnoozedUntil.IsZero()
+}
+
+// decorate sets the acknowledgement and snooze of an alert. e.mu must be held.
+func (e *Engine) decorate(alert *Alert) {
+	if alert.State != StateResolved {
+		alert.Acknowledged = find(e.journal.Acknowledged, alert.Rule, alert.Path) >= 0
+	}
+
+	alert.SnoozedUntil = time.Time{}
+	if i := find(e.journal.Snoozed, alert.Rule, alert.Path); i >= 0 && time.Now().Before(e.journal.Snoozed[i].Until) {
+		alert.SnoozedUntil = e.journal.Snoozed[i].Until
+	}
+}
+
+// saveJournal writes the journal, logging failures. e.mu must be held.
+func (e *Engine) saveJournal() error {
+	if e.journalPath == "" {
+		return nil
+	}
+	if err := e.journal.save(e.journalPath); err != nil {
+		logs.Error("%v", err)
+		return err
+	}
+	return nil
+}
+
+// Subscribe returns a channel receiving every alert transition. Events are
+// buffered, but subscribers too slow to keep up miss them, and must call
+// Unsubscribe when done.
+func (e *Engine) Subscribe() <-chan Event {
+	ch := make(chan Event, 64)
+
+	e.mu.Lock()
+	e.subscribers[ch] = struct{}{}
+	e.mu.Unlock()
+
+	return ch
+}
+
+// Unsubscribe stops delivering transitions to a channel returned by Subscribe
+func (e *Engine) Unsubscribe(sub <-chan Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for ch := range e.subscribers {
+		if ch == sub {
+			delete(e.subscribers, ch)
+			close(ch)
+			return
+		}
+	}
+}
+
+// notify sends an event to every subscriber. e.mu must be held.
+func (e *Engine) notify(event Event) {
+	for ch := range e.subscribers {
+		select {
+		case ch <- event:
+		default:
+			logs.Error("Alert subscriber is not keeping up, dropping event")
+		}
+	}
+}
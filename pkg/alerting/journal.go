@@ -0,0 +1,114 @@
+package alerting
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// mark records an acknowledgement or snooze of the alert of a rule and path
+type mark struct {
+	Rule  string    `json:"rule"`
+	Path  string    `json:"path"`
+	Until time.Time `json:"until,omitempty"` // End of a snooze, zero for acknowledgements
+}
+
+// journal is the alert state kept across restarts: the latest resolved
+// alerts, and the acknowledged and snoozed ones
+type journal struct {
+	Resolved     []Alert `json:"resolved"` // Newest first
+	Acknowledged []mark  `json:"acknowledged"`
+	Snoozed      []mark  `json:"snoozed"`
+}
+
+// loadJournal reads the journal file, returning an empty journal when it
+// doesn't exist yet
+func loadJournal(path string) (*journal, error) {
+	j := &journal{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return j, nil
+		}
+		return j, fmt.Errorf("failed to read alert journal: %v", err)
+	}
+
+	if err := json.Unmarshal(data, j); err != nil {
+		return &journal{}, fmt.Errorf("failed to parse alert journal: %v", err)
+	}
+	return j, nil
+}
+
+// save writes the journal file, replacing it atomically
+func (j *journal) save(path string) error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert journal: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create alert journal directory: %v", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write alert journal: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace alert journal: %v", err)
+	}
+	return nil
+}
+
+// addResolved records a resolved alert, keeping the newest size ones
+func (j *journal) addResolved(alert Alert, size int) {
+	size = max(size, 0)
+	j.Resolved = append([]Alert{alert}, j.Resolved...)
+	if len(j.Resolved) > size {
+		j.Resolved = j.Resolved[:size]
+	}
+}
+
+// find returns the index of the mark of a rule and path, or -1
+func find(marks []mark, rule, path string) int {
+	for i, m := range marks {
+		if m.Rule == rule && m.Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// set adds or replaces the mark of a rule and path
+func set(marks []mark, m mark) []mark {
+	if i := find(marks, m.Rule, m.Path); i >= 0 {
+		marks[i] = m
+		return marks
+	}
+	return append(marks, m)
+}
+
+// unset removes the mark of a rule and path, reporting whether there was one
+func unset(marks []mark, rule, path string) ([]mark, bool) {
+	i := find(marks, rule, path)
+	if i < 0 {
+		return marks, false
+	}
+	return append(marks[:i], marks[i+1:]...), true
+}
+
+// expire removes the snoozes that ended before now, reporting whether any did
+func (j *journal) expire(now time.Time) bool {
+	kept := j.Snoozed[:0]
+	for _, m := range j.Snoozed {
+		if m.Until.After(now) {
+			kept = append(kept, m)
+		}
+	}
+	expired := len(kept) != len(j.Snoozed)
+	j.Snoozed = kept
+	return expired
+}
@@ -0,0 +1,67 @@
+package alerting
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"p-monitor/pkg/config"
+)
+
+// rule is a validated alert rule ready for evaluation
+type rule struct {
+	config.AlertRule
+	pattern *regexp.Regexp // Matches the metric paths the rule applies to
+	hold    time.Duration  // How long the condition must hold before firing
+}
+
+// compileRule validates a configured rule and compiles its metric pattern
+func compileRule(r config.AlertRule) (*rule, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	hold, _ := r.ForDuration()
+
+	// "[*]" matches any list item, everything else literally
+	expr := strings.ReplaceAll(regexp.QuoteMeta(r.Metric), `\[\*\]`, `\[[^\]]+\]`)
+	pattern, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return nil, fmt.Errorf("alert rule %q has an invalid metric: %v", r.Name, err)
+	}
+
+	return &rule{AlertRule: r, pattern: pattern, hold: hold}, nil
+}
+
+// matches reports whether the rule applies to a metric path
+func (r *rule) matches(path string) bool {
+	return r.pattern.MatchString(path)
+}
+
+// breached reports whether a value meets the rule's firing condition
+func (r *rule) breached(value float64) bool {
+	return compare(value, r.Comparison, r.Threshold)
+}
+
+// cleared reports whether a value of a firing alert resolves it. With a clear
+// threshold the value must cross it, so values between both thresholds keep
+// the alert firing.
+func (r *rule) cleared(value float64) bool {
+	return !compare(value, r.Comparison, r.ClearThreshold())
+}
+
+// compare applies a comparison operator
+func compare(value float64, comparison string, threshold float64) bool {
+	switch comparison {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	default:
+		return false
+	}
+}
@@ -0,0 +1,435 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/history"
+	"p-monitor/pkg/monitor"
+	"p-monitor/pkg/types"
+)
+
+const (
+	// unixPrefix marks an API address as a Unix domain socket path
+	unixPrefix = "unix:"
+
+	// shutdownTimeout bounds how long in-flight requests may take on shutdown
+	shutdownTimeout = 5 * time.Second
+
+	// streamKeepAlive is how often a comment is sent to idle event streams
+	streamKeepAlive = 15 * time.Second
+
+	// maxConfigSize limits the size of a configuration update request body
+	maxConfigSize = 64 * 1024
+
+	// redacted replaces secrets in configurations returned by the API
+	redacted = "redacted"
+)
+
+// Server serves the local JSON API
+type Server struct {
+	monitor *monitor.Monitor
+	config  *config.Config
+	token   string // Authorizes configuration updates, empty to refuse them
+}
+
+// historyResponse is the body of a history query
+type historyResponse struct {
+	Path   string          `json:"path"`
+	Points []history.Point `json:"points"`
+	Stats  *history.Stats  `json:"stats,omitempty"`
+}
+
+// errorResponse is the body of every error response
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// New creates a new API server
+func New(m *monitor.Monitor, cfg *config.Config) *Server {
+	return &Server{
+		monitor: m,
+		config:  cfg,
+	}
+}
+
+// Serve serves the API on address until ctx is cancelled. The address is
+// either a TCP address such as "127.0.0.1:9102" or "unix:" followed by a
+// socket path. Configuration updates must carry the token of
+// config.APITokenPath, which is created on the first start.
+func (s *Server) Serve(ctx context.Context, address string) error {
+	token, err := loadToken(config.APITokenPath())
+	if err != nil {
+		return err
+	}
+	s.token = token
+
+	listener, err := listen(address)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Handler: s.Handler(),
+		BaseContext: func(net.Listener) context.Context {
+			// Cancelling ctx also ends the event streams
+			return ctx
+		},
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logs.Info("Serving API on %s", address)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Handler returns the API routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/metrics", s.handleMetrics)
+	mux.HandleFunc("GET /api/v1/metrics/{collector}", s.handleCollector)
+	mux.HandleFunc("GET /api/v1/config", s.handleGetConfig)
+	mux.HandleFunc("PUT /api/v1/config", s.handlePutConfig)
+	mux.HandleFunc("GET /api/v1/history", s.handleHistoryPaths)
+	mux.HandleFunc("GET /api/v1/history/{path}", s.handleHistory)
+	mux.HandleFunc("GET /api/v1/stream", s.handleStream)
+	return mux
+}
+
+// handleMetrics returns the latest snapshot
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics := s.monitor.GetMetrics()
+	if metrics == nil {
+		writeError(w, http.StatusServiceUnavailable, "no metrics collected yet")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, metrics)
+}
+
+// handleCollector returns the part of the latest snapshot produced by a single collector
+func (s *Server) handleCollector(w http.ResponseWriter, r *http.Request) {
+	metrics := s.monitor.GetMetrics()
+	if metrics == nil {
+		writeError(w, http.StatusServiceUnavailable, "no metrics collected yet")
+		return
+	}
+
+	collector := r.PathValue("collector")
+	value, ok := collectorValue(metrics, collector)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown collector %q", collector))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, value)
+}
+
+// handleHistoryPaths lists the metric paths held in the history
+func (s *Server) handleHistoryPaths(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.monitor.History().Paths())
+}
+
+// handleHistory returns the values and min/max/avg of a metric path. The range
+// is given either as "from" and "to" RFC 3339 times or as a "range" duration
+// ending now, such as "15m"; without either the whole history is returned.
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	path := r.PathValue("path")
+	resp := historyResponse{
+		Path:   path,
+		Points: s.monitor.History().Series(path, from, to),
+	}
+	if resp.Points == nil {
+		resp.Points = []history.Point{}
+	}
+	if stats, ok := s.monitor.History().Aggregate(path, from, to); ok {
+		resp.Stats = &stats
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleGetConfig returns the current configuration
+func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, redact(s.config.Snapshot()))
+}
+
+// handlePutConfig replaces the configuration, applying it to the running monitor.
+// Fields missing from the body keep their current value. Alert actions can
+// only be changed in the configuration file.
+func (s *Server) handlePutConfig(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "configuration updates need the bearer token of "+config.APITokenPath())
+		return
+	}
+
+	// Decode before taking the configuration lock, so that slow clients don't
+	// hold back its readers
+	updated := redact(s.config.Snapshot())
+	actions := updated.AlertActions
+	updated.AlertActions = nil
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxConfigSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&updated); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid configuration: %v", err))
+		return
+	}
+
+	// The actions returned by GET may be sent back unchanged
+	if updated.AlertActions != nil && !sameJSON(updated.AlertActions, actions) {
+		writeError(w, http.StatusForbidden, "alert_actions can only be changed in the configuration file")
+		return
+	}
+
+	err := s.config.Update(func(cfg *config.Config) {
+		updated.AlertActions = cfg.AlertActions
+		*cfg = updated
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	logs.Info("Configuration updated through the API")
+	s.monitor.Reconfigure()
+	writeJSON(w, http.StatusOK, redact(s.config.Snapshot()))
+}
+
+// authorized reports whether a request carries the API token
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// handleStream streams every new collection as server-sent events
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	updates := s.monitor.Subscribe()
+	defer s.monitor.Unsubscribe(updates)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	// Send the latest snapshot right away so clients don't wait a full interval
+	if metrics := s.monitor.GetMetrics(); metrics != nil {
+		if err := writeEvent(w, metrics); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case metrics, ok := <-updates:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, metrics); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// collectorValue returns the part of metrics produced by the named collector
+func collectorValue(metrics *types.SystemMetrics, collector string) (any, bool) {
+	switch collector {
+	case "disk":
+		return metrics.Disk, true
+	case "mounts":
+		return metrics.Mounts, true
+	case "drives":
+		return metrics.Drives, true
+	case "memory":
+		return metrics.Memory, true
+	case "cpu":
+		return metrics.CPU, true
+	case "gpus":
+		return metrics.GPUs, true
+	case "network":
+		return metrics.Network, true
+	case "services":
+		return metrics.Services, true
+	case "slices":
+		return metrics.Slices, true
+	case "containers":
+		return metrics.Containers, true
+	case "collectors":
+		return metrics.Collectors, true
+	default:
+		return nil, false
+	}
+}
+
+// parseTimeRange reads the "from", "to" and "range" query parameters
+func parseTimeRange(r *http.Request) (time.Time, time.Time, error) {
+	var from, to time.Time
+	query := r.URL.Query()
+
+	if value := query.Get("range"); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid range %q: %v", value, err)
+		}
+		from = time.Now().Add(-duration)
+	}
+
+	for name, target := range map[string]*time.Time{"from": &from, "to": &to} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid %s time %q: %v", name, value, err)
+		}
+		*target = parsed
+	}
+
+	return from, to, nil
+}
+
+// listen opens a TCP or Unix domain socket listener for address
+func listen(address string) (net.Listener, error) {
+	path, isUnix := strings.CutPrefix(address, unixPrefix)
+	if !isUnix {
+		return net.Listen("tcp", address)
+	}
+
+	// Remove a socket left behind by a previous run
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket: %v", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the current user may talk to the API
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %v", err)
+	}
+
+	return listener, nil
+}
+
+// loadToken reads the API token from path, creating a random one readable by
+// the current user only when there is none
+func loadToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read API token: %v", err)
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate API token: %v", err)
+	}
+	token := hex.EncodeToString(random)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create API token directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write API token: %v", err)
+	}
+	logs.Info("Created API token in %s", path)
+	return token, nil
+}
+
+// redact hides the secrets of a configuration, such as the webhook URLs that
+// often embed credentials
+func redact(cfg config.Config) config.Config {
+	for i := range cfg.AlertActions {
+		if cfg.AlertActions[i].WebhookURL != "" {
+			cfg.AlertActions[i].WebhookURL = redacted
+		}
+	}
+	return cfg
+}
+
+// sameJSON reports whether two lists of alert actions encode to the same JSON
+func sameJSON(a, b []config.AlertAction) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+// writeEvent writes metrics as a server-sent "metrics" event
+func writeEvent(w http.ResponseWriter, metrics *types.SystemMetrics) error {
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: metrics\ndata: %s\n\n", data)
+	return err
+}
+
+// writeJSON writes value as a JSON response
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logs.Error("Failed to write API response: %v", err)
+	}
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
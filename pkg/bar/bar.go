@@ -0,0 +1,180 @@
+package bar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/monitor"
+	"p-monitor/pkg/types"
+)
+
+// Output protocols
+const (
+	ProtocolWaybar = "waybar" // One waybar custom module JSON object per line
+	ProtocolI3bar  = "i3bar"  // i3bar JSON protocol, also used by swaybar
+	ProtocolPlain  = "plain"  // One line of text per update, e.g. for polybar
+)
+
+// Colors of i3bar blocks by state
+const (
+	warningColor  = "#FFB000"
+	criticalColor = "#FF3030"
+)
+
+// Classes of waybar modules by state
+const (
+	classNormal   = "normal"
+	classWarning  = "warning"
+	classCritical = "critical"
+)
+
+// waybarModule is the JSON object read by a waybar custom module with "return-type": "json"
+type waybarModule struct {
+	Text       string `json:"text"`
+	Tooltip    string `json:"tooltip"`
+	Class      string `json:"class"`
+	Percentage int    `json:"percentage"`
+}
+
+// i3barHeader starts the i3bar protocol
+type i3barHeader struct {
+	Version int `json:"version"`
+}
+
+// i3barBlock is a single block of an i3bar status line
+type i3barBlock struct {
+	Name     string `json:"name"`
+	FullText string `json:"full_text"`
+	Color    string `json:"color,omitempty"`
+	Urgent   bool   `json:"urgent,omitempty"`
+}
+
+// Bar writes the metrics to a status bar on every update
+type Bar struct {
+	monitor  *monitor.Monitor
+	config   *config.Config
+	protocol string
+	format   string
+	out      io.Writer
+}
+
+// New creates a new status bar output. An empty format uses the configured bar_format.
+func New(m *monitor.Monitor, cfg *config.Config, protocol, format string, out io.Writer) (*Bar, error) {
+	switch protocol {
+	case ProtocolWaybar, ProtocolI3bar, ProtocolPlain:
+	default:
+		return nil, fmt.Errorf("unknown protocol %q, expected waybar, i3bar or plain", protocol)
+	}
+
+	if format == "" {
+		format = cfg.Snapshot().BarFormat
+	}
+
+	return &Bar{
+		monitor:  m,
+		config:   cfg,
+		protocol: protocol,
+		format:   format,
+		out:      out,
+	}, nil
+}
+
+// Run writes a status line for every update until ctx is cancelled or the
+// bar stops reading
+func (b *Bar) Run(ctx context.Context) error {
+	updates := b.monitor.Subscribe()
+	defer b.monitor.Unsubscribe(updates)
+
+	if b.protocol == ProtocolI3bar {
+		if err := b.writeI3barHeader(); err != nil {
+			return err
+		}
+	}
+
+	if metrics := b.monitor.GetMetrics(); metrics != nil {
+		if err := b.write(metrics); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case metrics, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if err := b.write(metrics); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// write writes a status line in the configured protocol
+func (b *Bar) write(metrics *types.SystemMetrics) error {
+	cfg := b.config.Snapshot()
+	temperatureUnit := cfg.TemperatureUnit
+	text := render(b.format, placeholders(metrics, temperatureUnit))
+	usage := highestUsage(metrics)
+	state := class(usage, cfg.TrayIconWarning, cfg.TrayIconCritical)
+
+	switch b.protocol {
+	case ProtocolWaybar:
+		return json.NewEncoder(b.out).Encode(waybarModule{
+			Text:       text,
+			Tooltip:    tooltip(metrics, temperatureUnit),
+			Class:      state,
+			Percentage: int(math.Round(usage)),
+		})
+	case ProtocolI3bar:
+		block := i3barBlock{Name: "p-monitor", FullText: text}
+		switch state {
+		case classWarning:
+			block.Color = warningColor
+		case classCritical:
+			block.Color = criticalColor
+			block.Urgent = true
+		}
+
+		line, err := json.Marshal([]i3barBlock{block})
+		if err != nil {
+			return err
+		}
+		// Every status line is an element of an endless JSON array
+		_, err = fmt.Fprintf(b.out, "%s,\n", line)
+		return err
+	default:
+		_, err := fmt.Fprintln(b.out, text)
+		return err
+	}
+}
+
+// writeI3barHeader writes the i3bar protocol header and opens the endless array
+func (b *Bar) writeI3barHeader() error {
+	header, err := json.Marshal(i3barHeader{Version: 1})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(b.out, "%s\n[\n", header)
+	return err
+}
+
+// class returns the state of the output for a usage percentage, given the
+// warning and critical thresholds in percent shared with the tray icon
+func class(usage, warning, critical float64) string {
+	switch {
+	case usage >= critical:
+		return classCritical
+	case usage >= warning:
+		return classWarning
+	default:
+		return classNormal
+	}
+}
@@ -0,0 +1,162 @@
+package bar
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"p-monitor/pkg/types"
+)
+
+// placeholderPattern matches a {name} placeholder of a format template
+var placeholderPattern = regexp.MustCompile(`\{([a-z0-9_]+)\}`)
+
+// placeholders returns the formatted value of every placeholder available for
+// metrics. Metrics that failed or are missing are rendered as "n/a".
+func placeholders(metrics *types.SystemMetrics, temperatureUnit string) map[string]string {
+	values := make(map[string]string)
+
+	if cpu := metrics.CPU; cpu != nil && cpu.Error == "" {
+		values["cpu"] = formatPercent(cpu.UsagePercent)
+		values["cpu_temp"] = formatTemperature(cpu.Temperature, temperatureUnit)
+	}
+
+	if memory := metrics.Memory; memory != nil && memory.Error == "" {
+		values["mem"] = formatPercent(memory.UsedPercent)
+		values["mem_used"] = types.FormatGigabytes(memory.Used)
+		values["mem_total"] = types.FormatGigabytes(memory.Total)
+	}
+
+	if disk := metrics.Disk; disk != nil && disk.Error == "" {
+		values["disk"] = formatPercent(disk.UsedPercent)
+		values["disk_used"] = types.FormatGigabytes(disk.Used)
+		values["disk_total"] = types.FormatGigabytes(disk.Total)
+	}
+
+	for i, gpu := range metrics.GPUs {
+		if gpu.Error != "" {
+			continue
+		}
+		usage := formatPercent(gpu.UsagePercent)
+		temperature := formatTemperature(gpu.Temperature, temperatureUnit)
+		values[fmt.Sprintf("gpu%d", i)] = usage
+		values[fmt.Sprintf("gpu%d_temp", i)] = temperature
+
+		// {gpu} and {gpu_temp} refer to the first GPU
+		if i == 0 {
+			values["gpu"] = usage
+			values["gpu_temp"] = temperature
+		}
+	}
+
+	return values
+}
+
+// render replaces every {name} placeholder of format with its value
+func render(format string, values map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(format, func(match string) string {
+		if value, ok := values[match[1:len(match)-1]]; ok {
+			return value
+		}
+		return "n/a"
+	})
+}
+
+// tooltip describes every metric on its own line
+func tooltip(metrics *types.SystemMetrics, temperatureUnit string) string {
+	var lines []string
+
+	if cpu := metrics.CPU; cpu != nil {
+		if cpu.Error != "" {
+			lines = append(lines, "CPU: n/a")
+		} else {
+			lines = append(lines, "CPU: "+formatPercent(cpu.UsagePercent)+temperatureSuffix(cpu.Temperature, temperatureUnit))
+		}
+	}
+
+	if memory := metrics.Memory; memory != nil {
+		if memory.Error != "" {
+			lines = append(lines, "RAM: n/a")
+		} else {
+			lines = append(lines, fmt.Sprintf("RAM: %s (%s of %s)", formatPercent(memory.UsedPercent), types.FormatGigabytes(memory.Used), types.FormatGigabytes(memory.Total)))
+		}
+	}
+
+	if disk := metrics.Disk; disk != nil {
+		if disk.Error != "" {
+			lines = append(lines, "HDD: n/a")
+		} else {
+			lines = append(lines, fmt.Sprintf("HDD: %s (%s of %s)", formatPercent(disk.UsedPercent), types.FormatGigabytes(disk.Used), types.FormatGigabytes(disk.Total)))
+		}
+	}
+
+	for _, mount := range metrics.Mounts {
+		if mount.Error != "" {
+			lines = append(lines, fmt.Sprintf("HDD %s: n/a", mount.Mount))
+		} else {
+			lines = append(lines, fmt.Sprintf("HDD %s: %s (%s of %s)", mount.Mount, formatPercent(mount.UsedPercent), types.FormatGigabytes(mount.Used), types.FormatGigabytes(mount.Total)))
+		}
+	}
+
+	for _, gpu := range metrics.GPUs {
+		if gpu.Error != "" {
+			lines = append(lines, fmt.Sprintf("%s: n/a", gpu.Name))
+		} else {
+			lines = append(lines, gpu.Name+": "+formatPercent(gpu.UsagePercent)+temperatureSuffix(gpu.Temperature, temperatureUnit))
+		}
+	}
+
+	lines = append(lines, "Updated "+metrics.Updated.Format("15:04:05"))
+	return strings.Join(lines, "\n")
+}
+
+// highestUsage returns the highest CPU, memory, filesystem or GPU usage in percent
+func highestUsage(metrics *types.SystemMetrics) float64 {
+	highest := 0.0
+	consider := func(percent float64) {
+		if percent > highest {
+			highest = percent
+		}
+	}
+
+	if cpu := metrics.CPU; cpu != nil && cpu.Error == "" {
+		consider(cpu.UsagePercent)
+	}
+	if memory := metrics.Memory; memory != nil && memory.Error == "" {
+		consider(memory.UsedPercent)
+	}
+	if disk := metrics.Disk; disk != nil && disk.Error == "" {
+		consider(disk.UsedPercent)
+	}
+	for _, mount := range metrics.Mounts {
+		if mount.Error == "" {
+			consider(mount.UsedPercent)
+		}
+	}
+	for _, gpu := range metrics.GPUs {
+		if gpu.Error == "" {
+			consider(gpu.UsagePercent)
+		}
+	}
+
+	return highest
+}
+
+// formatPercent formats a usage percentage
+func formatPercent(percent float64) string {
+	return fmt.Sprintf("%.0f%%", percent)
+}
+
+// formatTemperature formats a Celsius temperature in the configured unit
+func formatTemperature(celsius float64, unit string) string {
+	return types.FormatReading(celsius, unit, 0)
+}
+
+// temperatureSuffix formats a temperature to append to a tooltip line, or
+// nothing when there is no sensor
+func temperatureSuffix(celsius float64, unit string) string {
+	if !types.HasTemperature(celsius) {
+		return ""
+	}
+	return " " + formatTemperature(celsius, unit)
+}
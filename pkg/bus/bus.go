@@ -0,0 +1,277 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/monitor"
+	"p-monitor/pkg/types"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+const (
+	// Name is the well-known session bus name owned by p-monitor
+	Name = "io.github.lfsc09.PMonitor"
+
+	// Interface is the D-Bus interface holding the metrics and methods
+	Interface = "io.github.lfsc09.PMonitor"
+
+	// Path is the object path of the monitor
+	Path = dbus.ObjectPath("/io/github/lfsc09/PMonitor")
+)
+
+// Properties exposing one metric family each. Families holding a single
+// value are dictionaries (a{sv}), the others arrays of dictionaries (aa{sv}),
+// keyed like the JSON output.
+const (
+	propertyDisk       = "Disk"
+	propertyMounts     = "Mounts"
+	propertyDrives     = "Drives"
+	propertyMemory     = "Memory"
+	propertyCPU        = "CPU"
+	propertyGPUs       = "GPUs"
+	propertyNetwork    = "Network"
+	propertyServices   = "Services"
+	propertySlices     = "Slices"
+	propertyContainers = "Containers"
+	propertyCollectors = "Collectors"
+	propertyUpdated    = "Updated"
+	propertyPaused     = "Paused"
+)
+
+// Service owns the p-monitor bus name and mirrors every collection to its properties
+type Service struct {
+	monitor *monitor.Monitor
+	config  *config.Config
+}
+
+// methods holds the D-Bus methods, kept apart from Service so that only
+// these are exported on the bus
+type methods struct {
+	monitor *monitor.Monitor
+	config  *config.Config
+}
+
+// New creates a new D-Bus service
+func New(m *monitor.Monitor, cfg *config.Config) *Service {
+	return &Service{
+		monitor: m,
+		config:  cfg,
+	}
+}
+
+// Serve connects to the session bus, owns Name and updates the properties
+// after every collection until ctx is cancelled
+func (s *Service) Serve(ctx context.Context) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to session bus: %v", err)
+	}
+	defer conn.Close()
+
+	handler := &methods{monitor: s.monitor, config: s.config}
+	if err := conn.Export(handler, Path, Interface); err != nil {
+		return fmt.Errorf("failed to export methods: %v", err)
+	}
+
+	props, err := prop.Export(conn, Path, prop.Map{Interface: initialProperties()})
+	if err != nil {
+		return fmt.Errorf("failed to export properties: %v", err)
+	}
+
+	node := &introspect.Node{
+		Name: string(Path),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       Interface,
+				Methods:    introspect.Methods(handler),
+				Properties: props.Introspection(Interface),
+			},
+		},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(node), Path, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return fmt.Errorf("failed to export introspection: %v", err)
+	}
+
+	reply, err := conn.RequestName(Name, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return fmt.Errorf("failed to request bus name: %v", err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("bus name %s is already owned", Name)
+	}
+
+	updates := s.monitor.Subscribe()
+	defer s.monitor.Unsubscribe(updates)
+
+	logs.Info("Serving D-Bus interface %s on the session bus", Name)
+
+	if metrics := s.monitor.GetMetrics(); metrics != nil {
+		s.update(props, metrics)
+	}
+
+	for {
+		select {
+		case metrics, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			s.update(props, metrics)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// update stores a collection in the properties, emitting PropertiesChanged
+func (s *Service) update(props *prop.Properties, metrics *types.SystemMetrics) {
+	props.SetMust(Interface, propertyDisk, toDict(metrics.Disk))
+	props.SetMust(Interface, propertyMounts, toDictList(metrics.Mounts))
+	props.SetMust(Interface, propertyDrives, toDictList(metrics.Drives))
+	props.SetMust(Interface, propertyMemory, toDict(metrics.Memory))
+	props.SetMust(Interface, propertyCPU, toDict(metrics.CPU))
+	props.SetMust(Interface, propertyGPUs, toDictList(metrics.GPUs))
+	props.SetMust(Interface, propertyNetwork, toDict(metrics.Network))
+	props.SetMust(Interface, propertyServices, toDictList(metrics.Services))
+	props.SetMust(Interface, propertySlices, toDictList(metrics.Slices))
+	props.SetMust(Interface, propertyContainers, toDictList(metrics.Containers))
+	props.SetMust(Interface, propertyCollectors, toDictList(metrics.Collectors))
+	props.SetMust(Interface, propertyPaused, s.monitor.Paused())
+	props.SetMust(Interface, propertyUpdated, metrics.Updated.Unix())
+}
+
+// initialProperties declares every property with an empty value of its type
+func initialProperties() map[string]*prop.Prop {
+	dict := func() *prop.Prop {
+		return &prop.Prop{Value: map[string]dbus.Variant{}, Emit: prop.EmitTrue}
+	}
+	dictList := func() *prop.Prop {
+		return &prop.Prop{Value: []map[string]dbus.Variant{}, Emit: prop.EmitTrue}
+	}
+
+	return map[string]*prop.Prop{
+		propertyDisk:       dict(),
+		propertyMounts:     dictList(),
+		propertyDrives:     dictList(),
+		propertyMemory:     dict(),
+		propertyCPU:        dict(),
+		propertyGPUs:       dictList(),
+		propertyNetwork:    dict(),
+		propertyServices:   dictList(),
+		propertySlices:     dictList(),
+		propertyContainers: dictList(),
+		propertyCollectors: dictList(),
+		propertyUpdated:    {Value: int64(0), Emit: prop.EmitTrue},
+		propertyPaused:     {Value: false, Emit: prop.EmitTrue},
+	}
+}
+
+// Refresh collects and publishes metrics immediately
+func (h *methods) Refresh() *dbus.Error {
+	h.monitor.Refresh()
+	return nil
+}
+
+// SetInterval changes the update interval, unit being "seconds" or "minutes"
+func (h *methods) SetInterval(value int32, unit string) *dbus.Error {
+	err := h.config.Update(func(cfg *config.Config) {
+		cfg.UpdateInterval = int(value)
+		cfg.TimeUnit = unit
+	})
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+
+	logs.Info("Update interval set to %d %s through D-Bus", value, unit)
+	h.monitor.Reconfigure()
+	return nil
+}
+
+// Pause stops scheduled collections
+func (h *methods) Pause() *dbus.Error {
+	h.monitor.Pause()
+	return nil
+}
+
+// Resume restarts scheduled collections
+func (h *methods) Resume() *dbus.Error {
+	h.monitor.Resume()
+	return nil
+}
+
+// toDict converts a metrics struct to a D-Bus dictionary keyed by its JSON field names
+func toDict(value any) map[string]dbus.Variant {
+	var fields map[string]any
+	if data, err := json.Marshal(value); err == nil {
+		json.Unmarshal(data, &fields)
+	}
+	return dictValue(fields)
+}
+
+// toDictList converts a list of metrics structs to a list of D-Bus dictionaries
+func toDictList[T any](values []*T) []map[string]dbus.Variant {
+	dicts := make([]map[string]dbus.Variant, 0, len(values))
+	for _, value := range values {
+		dicts = append(dicts, toDict(value))
+	}
+	return dicts
+}
+
+// dictValue converts decoded JSON fields to variants, leaving out null fields
+// which have no D-Bus representation
+func dictValue(fields map[string]any) map[string]dbus.Variant {
+	dict := make(map[string]dbus.Variant, len(fields))
+	for key, value := range fields {
+		if value == nil {
+			continue
+		}
+		dict[key] = dbus.MakeVariant(variantValue(value))
+	}
+	return dict
+}
+
+// variantValue converts a decoded JSON value to a value with a D-Bus signature.
+// Lists of numbers and strings become typed arrays (ad, as).
+func variantValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		return dictValue(v)
+	case []any:
+		if numbers, ok := typedList[float64](v); ok {
+			return numbers
+		}
+		if strings, ok := typedList[string](v); ok {
+			return strings
+		}
+		variants := make([]dbus.Variant, 0, len(v))
+		for _, item := range v {
+			if item != nil {
+				variants = append(variants, dbus.MakeVariant(variantValue(item)))
+			}
+		}
+		return variants
+	default:
+		return v
+	}
+}
+
+// typedList converts a list whose items all have type T
+func typedList[T any](items []any) ([]T, bool) {
+	list := make([]T, 0, len(items))
+	for _, item := range items {
+		typed, ok := item.(T)
+		if !ok {
+			return nil, false
+		}
+		list = append(list, typed)
+	}
+	return list, true
+}
@@ -0,0 +1,101 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds how long ExecRunner lets a command run, so that a
+// hanging tool, such as smartctl on an unresponsive drive, can't stall the
+// collections waiting for it
+const DefaultTimeout = 15 * time.Second
+
+// ErrTimeout is returned for commands killed after running past their timeout
+var ErrTimeout = errors.New("timed out")
+
+// Runner runs the external tools the collectors rely on
+type Runner interface {
+	// LookPath reports the path of an executable, failing if it isn't installed
+	LookPath(name string) (string, error)
+
+	// Run runs a command to completion and returns its captured result. An
+	// error is only returned when the command could not be started or
+	// didn't complete in time.
+	Run(name string, args ...string) (*Result, error)
+}
+
+// Result holds the captured output of a command
+type Result struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// Err returns an error describing a non zero exit code, or nil on success
+func (r *Result) Err() error {
+	if r.ExitCode == 0 {
+		return nil
+	}
+
+	message := strings.TrimSpace(r.Stderr)
+	if message == "" {
+		// Some tools, such as nvidia-smi, report failures on stdout
+		message = strings.TrimSpace(r.Stdout)
+	}
+	return fmt.Errorf("exit status %d: %s", r.ExitCode, message)
+}
+
+// ExecRunner runs commands on the local machine
+type ExecRunner struct {
+	Timeout time.Duration // Limit of each command, DefaultTimeout when zero
+}
+
+// LookPath reports the path of an executable in PATH
+func (ExecRunner) LookPath(name string) (string, error) {
+	return exec.LookPath(name)
+}
+
+// Run runs a command and captures its stdout, stderr and exit code. Commands
+// running longer than the timeout are killed.
+func (r ExecRunner) Run(name string, args ...string) (*Result, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Don't wait for children that inherited the output pipes once killed
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("%s %w after %v", name, ErrTimeout, timeout)
+	}
+
+	result := &Result{
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,39 @@
+package command
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExecRunnerRun(t *testing.T) {
+	result, err := ExecRunner{}.Run("sh", "-c", "echo out; echo err >&2; exit 3")
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	want := Result{Stdout: "out\n", Stderr: "err\n", ExitCode: 3}
+	if *result != want {
+		t.Errorf("Run() = %+v, want %+v", *result, want)
+	}
+	if err := result.Err(); err == nil || err.Error() != "exit status 3: err" {
+		t.Errorf("Err() = %v, want exit status 3: err", err)
+	}
+}
+
+func TestExecRunnerTimeout(t *testing.T) {
+	// The background child keeps the output pipes open after the shell is killed
+	start := time.Now()
+	_, err := ExecRunner{Timeout: 100 * time.Millisecond}.Run("sh", "-c", "sleep 10 & sleep 10")
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("Run() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run() returned after %v, want it killed after the timeout", elapsed)
+	}
+}
+
+func TestExecRunnerNotInstalled(t *testing.T) {
+	if _, err := (ExecRunner{}).Run("p-monitor-missing-tool"); err == nil {
+		t.Error("Run() of a missing command succeeded")
+	}
+}
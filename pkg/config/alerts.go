@@ -0,0 +1,169 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"text/template"
+	"time"
+)
+
+// AlertRule describes a threshold alert on a metric path
+type AlertRule struct {
+	Name       string   `json:"name"`
+	Metric     string   `json:"metric"`                    // Metric path, "[*]" matching any list item, e.g. "gpus[*].temperature"
+	Comparison string   `json:"comparison"`                // ">", ">=", "<" or "<="
+	Threshold  float64  `json:"threshold"`                 // Value the metric is compared against
+	For        string   `json:"for,omitempty"`             // How long the condition must hold before firing, e.g. "5m"
+	Clear      *float64 `json:"clear_threshold,omitempty"` // Value resolving a firing alert, defaults to threshold
+	Severity   string   `json:"severity"`                  // "info", "warning" or "critical"
+}
+
+// Validate checks that the rule is usable
+func (r *AlertRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("alert rule needs a name")
+	}
+	if r.Metric == "" {
+		return fmt.Errorf("alert rule %q needs a metric", r.Name)
+	}
+
+	switch r.Comparison {
+	case ">", ">=":
+		if r.Clear != nil && *r.Clear > r.Threshold {
+			return fmt.Errorf("alert rule %q clear_threshold must not be above threshold", r.Name)
+		}
+	case "<", "<=":
+		if r.Clear != nil && *r.Clear < r.Threshold {
+			return fmt.Errorf("alert rule %q clear_threshold must not be below threshold", r.Name)
+		}
+	default:
+		return fmt.Errorf("alert rule %q comparison must be \">\", \">=\", \"<\" or \"<=\", got %q", r.Name, r.Comparison)
+	}
+
+	if _, err := r.ForDuration(); err != nil {
+		return fmt.Errorf("alert rule %q has an invalid for duration: %v", r.Name, err)
+	}
+
+	switch r.Severity {
+	case "info", "warning", "critical":
+	default:
+		return fmt.Errorf("alert rule %q severity must be \"info\", \"warning\" or \"critical\", got %q", r.Name, r.Severity)
+	}
+	return nil
+}
+
+// ForDuration returns how long the condition must hold before the alert fires
+func (r *AlertRule) ForDuration() (time.Duration, error) {
+	if r.For == "" {
+		return 0, nil
+	}
+
+	duration, err := time.ParseDuration(r.For)
+	if err != nil {
+		return 0, err
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return duration, nil
+}
+
+// ClearThreshold returns the value resolving a firing alert
+func (r *AlertRule) ClearThreshold() float64 {
+	if r.Clear != nil {
+		return *r.Clear
+	}
+	return r.Threshold
+}
+
+// AlertAction describes what to do when alerts fire or resolve
+type AlertAction struct {
+	Name            string   `json:"name"`
+	Rules           []string `json:"rules,omitempty"`            // Names of the rules triggering the action, empty for all
+	On              []string `json:"on,omitempty"`               // "firing" and/or "resolved", empty for both
+	WebhookURL      string   `json:"webhook_url,omitempty"`      // URL receiving a POST of the payload
+	WebhookTemplate string   `json:"webhook_template,omitempty"` // text/template of the JSON payload, empty for the event as JSON
+	Command         []string `json:"command,omitempty"`          // Program and arguments, given the alert in PMONITOR_ALERT_* variables
+	Timeout         string   `json:"timeout,omitempty"`          // Limit of each attempt, defaults to "10s"
+	Retries         int      `json:"retries,omitempty"`          // Additional webhook attempts after failures
+}
+
+// Validate checks that the action is usable
+func (a *AlertAction) Validate() error {
+	if a.Name == "" {
+		return fmt.Errorf("alert action needs a name")
+	}
+	if a.WebhookURL == "" && len(a.Command) == 0 {
+		return fmt.Errorf("alert action %q needs a webhook_url or a command", a.Name)
+	}
+	for _, on := range a.On {
+		if on != "firing" && on != "resolved" {
+			return fmt.Errorf("alert action %q on must list \"firing\" or \"resolved\", got %q", a.Name, on)
+		}
+	}
+	if _, err := a.ParseTemplate(); err != nil {
+		return fmt.Errorf("alert action %q has an invalid webhook_template: %v", a.Name, err)
+	}
+	if _, err := a.TimeoutDuration(); err != nil {
+		return fmt.Errorf("alert action %q has an invalid timeout: %v", a.Name, err)
+	}
+	if a.Retries < 0 {
+		return fmt.Errorf("alert action %q retries must not be negative, got %d", a.Name, a.Retries)
+	}
+	return nil
+}
+
+// checkCommandActions checks that every action of updated running a command is
+// already in current unchanged, so that commands only come from the
+// configuration file and never from the tray, D-Bus, control socket or API
+func checkCommandActions(current, updated []AlertAction) error {
+	for i := range updated {
+		action := &updated[i]
+		if len(action.Command) == 0 {
+			continue
+		}
+
+		unchanged := slices.ContainsFunc(current, func(a AlertAction) bool {
+			return reflect.DeepEqual(a, *action)
+		})
+		if !unchanged {
+			return fmt.Errorf("alert action %q runs a command and can only be changed in the configuration file", action.Name)
+		}
+	}
+	return nil
+}
+
+// ParseTemplate parses the webhook payload template, returning nil when there
+// is none. Besides the text/template builtins, templates can use "json" to
+// encode a value, e.g. {"text": {{json .Alert.Rule}}}.
+func (a *AlertAction) ParseTemplate() (*template.Template, error) {
+	if a.WebhookTemplate == "" {
+		return nil, nil
+	}
+
+	funcs := template.FuncMap{
+		"json": func(value any) (string, error) {
+			data, err := json.Marshal(value)
+			return string(data), err
+		},
+	}
+	return template.New(a.Name).Funcs(funcs).Parse(a.WebhookTemplate)
+}
+
+// TimeoutDuration returns the limit of each attempt of the action
+func (a *AlertAction) TimeoutDuration() (time.Duration, error) {
+	if a.Timeout == "" {
+		return 10 * time.Second, nil
+	}
+
+	duration, err := time.ParseDuration(a.Timeout)
+	if err != nil {
+		return 0, err
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return duration, nil
+}
@@ -3,24 +3,243 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"p-monitor/pkg/types"
 )
 
+// updateMu serializes configuration changes made concurrently by the tray and the API
+var updateMu sync.Mutex
+
 // Config holds the application configuration
 type Config struct {
-	UpdateInterval  int    `json:"update_interval"`
-	TimeUnit        string `json:"time_unit"`        // "seconds" or "minutes"
-	TemperatureUnit string `json:"temperature_unit"` // "celsius" or "fahrenheit"
+	UpdateInterval   int           `json:"update_interval"`
+	TimeUnit         string        `json:"time_unit"`          // "seconds" or "minutes"
+	TemperatureUnit  string        `json:"temperature_unit"`   // "celsius" or "fahrenheit"
+	OutputFile       string        `json:"output_file"`        // JSON snapshot written on every update, empty to disable
+	MetricsAddress   string        `json:"metrics_address"`    // OpenMetrics listener, e.g. "127.0.0.1:9101", empty to disable
+	APIAddress       string        `json:"api_address"`        // REST API listener on loopback, "127.0.0.1:9102" or "unix:/path.sock", empty to disable
+	BarFormat        string        `json:"bar_format"`         // Template of the "p-monitor bar" text, e.g. "CPU {cpu} RAM {mem}"
+	HistorySize      int           `json:"history_size"`       // Number of collections kept in memory for trends
+	DataMaxSizeMB    int           `json:"data_max_size_mb"`   // Size cap of the on-disk history in ~/.p-monitor/data, 0 to disable it
+	AlertRules       []AlertRule   `json:"alert_rules"`        // Threshold alerts evaluated on every collection
+	AlertActions     []AlertAction `json:"alert_actions"`      // Webhooks and commands run on alert transitions
+	AlertHistorySize int           `json:"alert_history_size"` // Number of resolved alerts kept in ~/.p-monitor/alerts.json
+	Notifications    bool          `json:"notifications"`      // Desktop notifications of firing and resolved alerts
+	ForecastWindow   string        `json:"forecast_window"`    // Recent usage the disk and memory forecasts are fitted to, e.g. "1h"
+	ForecastHorizon  string        `json:"forecast_horizon"`   // Forecasts shown and notified when full within it, e.g. "24h", "0" to disable
+	TrayIcon         string        `json:"tray_icon"`          // "bars", "sparkline" or "static" for assets/icon.png
+	TrayIconMetrics  []string      `json:"tray_icon_metrics"`  // Metrics drawn in the tray icon: "cpu", "memory" and/or "gpu"
+	TrayIconWarning  float64       `json:"tray_icon_warning"`  // Usage percentage drawn in yellow
+	TrayIconCritical float64       `json:"tray_icon_critical"` // Usage percentage drawn in red
+
+	loadErr error // Why the configuration file failed to load, which then must not be overwritten
 }
 
 // Default returns the default configuration
 func Default() *Config {
 	return &Config{
-		UpdateInterval:  5,
-		TimeUnit:        "seconds",
-		TemperatureUnit: "celsius",
+		UpdateInterval:   5,
+		TimeUnit:         "seconds",
+		TemperatureUnit:  types.Celsius,
+		OutputFile:       "",
+		MetricsAddress:   "",
+		APIAddress:       "",
+		BarFormat:        "CPU {cpu} {cpu_temp} RAM {mem} HDD {disk}",
+		HistorySize:      720,
+		DataMaxSizeMB:    512,
+		AlertRules:       []AlertRule{},
+		AlertActions:     []AlertAction{},
+		AlertHistorySize: 20,
+		Notifications:    true,
+		ForecastWindow:   "1h",
+		ForecastHorizon:  "24h",
+		TrayIcon:         "bars",
+		TrayIconMetrics:  []string{"cpu", "memory", "gpu"},
+		TrayIconWarning:  70,
+		TrayIconCritical: 90,
+	}
+}
+
+// Fallback returns the default configuration to run with when the
+// configuration file failed to load with err. It is never saved, leaving the
+// file for the user to fix and reload.
+func Fallback(err error) *Config {
+	cfg := Default()
+	cfg.loadErr = err
+	return cfg
+}
+
+// Validate checks that the configuration values are usable
+func (c *Config) Validate() error {
+	if c.UpdateInterval < 1 {
+		return fmt.Errorf("update_interval must be at least 1, got %d", c.UpdateInterval)
+	}
+	if c.TimeUnit != "seconds" && c.TimeUnit != "minutes" {
+		return fmt.Errorf("time_unit must be \"seconds\" or \"minutes\", got %q", c.TimeUnit)
+	}
+	if c.TemperatureUnit != types.Celsius && c.TemperatureUnit != types.Fahrenheit {
+		return fmt.Errorf("temperature_unit must be \"celsius\" or \"fahrenheit\", got %q", c.TemperatureUnit)
+	}
+	if !isLocalAddress(c.APIAddress) {
+		return fmt.Errorf("api_address must be a loopback TCP address or \"unix:\" followed by a socket path, got %q", c.APIAddress)
+	}
+	if c.HistorySize < 1 {
+		return fmt.Errorf("history_size must be at least 1, got %d", c.HistorySize)
+	}
+	if c.DataMaxSizeMB < 0 {
+		return fmt.Errorf("data_max_size_mb must not be negative, got %d", c.DataMaxSizeMB)
+	}
+
+	if c.AlertHistorySize < 0 {
+		return fmt.Errorf("alert_history_size must not be negative, got %d", c.AlertHistorySize)
+	}
+	if window, err := c.ForecastWindowDuration(); err != nil || window <= 0 {
+		return fmt.Errorf("forecast_window must be a positive duration, got %q", c.ForecastWindow)
+	}
+	if horizon, err := c.ForecastHorizonDuration(); err != nil || horizon < 0 {
+		return fmt.Errorf("forecast_horizon must be a duration, got %q", c.ForecastHorizon)
+	}
+	if c.TrayIcon != "bars" && c.TrayIcon != "sparkline" && c.TrayIcon != "static" {
+		return fmt.Errorf("tray_icon must be \"bars\", \"sparkline\" or \"static\", got %q", c.TrayIcon)
+	}
+	if len(c.TrayIconMetrics) == 0 && c.TrayIcon != "static" {
+		return fmt.Errorf("tray_icon_metrics must list at least one metric")
+	}
+	for _, metric := range c.TrayIconMetrics {
+		if metric != "cpu" && metric != "memory" && metric != "gpu" {
+			return fmt.Errorf("tray_icon_metrics must list \"cpu\", \"memory\" or \"gpu\", got %q", metric)
+		}
+	}
+	if c.TrayIconWarning < 0 || c.TrayIconWarning > c.TrayIconCritical || c.TrayIconCritical > 100 {
+		return fmt.Errorf("tray_icon_warning and tray_icon_critical must satisfy 0 <= warning <= critical <= 100, got %g and %g", c.TrayIconWarning, c.TrayIconCritical)
+	}
+
+	names := make(map[string]bool, len(c.AlertRules))
+	for i := range c.AlertRules {
+		rule := &c.AlertRules[i]
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("alert rule name %q is used more than once", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+
+	actions := make(map[string]bool, len(c.AlertActions))
+	for i := range c.AlertActions {
+		action := &c.AlertActions[i]
+		if err := action.Validate(); err != nil {
+			return err
+		}
+		if actions[action.Name] {
+			return fmt.Errorf("alert action name %q is used more than once", action.Name)
+		}
+		actions[action.Name] = true
+	}
+	return nil
+}
+
+// Update applies fn to a copy of the configuration and, if the result is
+// valid and leaves the command actions alone, stores and saves it
+func (c *Config) Update(fn func(*Config)) error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	updated := c.clone()
+	fn(&updated)
+	if err := updated.Validate(); err != nil {
+		return err
+	}
+	if err := checkCommandActions(c.AlertActions, updated.AlertActions); err != nil {
+		return err
+	}
+
+	*c = updated
+	return c.Save()
+}
+
+// Snapshot returns a copy of the configuration that is safe to read while
+// it is being updated
+func (c *Config) Snapshot() Config {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	return c.clone()
+}
+
+// clone returns a deep copy of the configuration, so that neither copy sees
+// changes made to the lists of the other
+func (c *Config) clone() Config {
+	cloned := *c
+	cloned.AlertRules = slices.Clone(c.AlertRules)
+	for i := range cloned.AlertRules {
+		rule := &cloned.AlertRules[i]
+		if rule.Clear != nil {
+			threshold := *rule.Clear
+			rule.Clear = &threshold
+		}
+	}
+	cloned.AlertActions = slices.Clone(c.AlertActions)
+	for i := range cloned.AlertActions {
+		action := &cloned.AlertActions[i]
+		action.Rules = slices.Clone(action.Rules)
+		action.On = slices.Clone(action.On)
+		action.Command = slices.Clone(action.Command)
+	}
+	cloned.TrayIconMetrics = slices.Clone(c.TrayIconMetrics)
+	return cloned
+}
+
+// Reload replaces the configuration with the contents of the configuration file
+func (c *Config) Reload() error {
+	loaded, err := Load()
+	if err != nil {
+		return err
 	}
+
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	*c = *loaded
+	return nil
+}
+
+// RestartRequired returns the JSON names of the settings that differ between
+// two configurations and are only read at startup
+func RestartRequired(running, updated Config) []string {
+	var names []string
+	if running.MetricsAddress != updated.MetricsAddress {
+		names = append(names, "metrics_address")
+	}
+	if running.APIAddress != updated.APIAddress {
+		names = append(names, "api_address")
+	}
+	if running.DataMaxSizeMB != updated.DataMaxSizeMB {
+		names = append(names, "data_max_size_mb")
+	}
+	if running.OutputFile != updated.OutputFile {
+		names = append(names, "output_file")
+	}
+	return names
+}
+
+// ForecastWindowDuration returns the recent usage forecasts are fitted to
+func (c *Config) ForecastWindowDuration() (time.Duration, error) {
+	return time.ParseDuration(c.ForecastWindow)
+}
+
+// ForecastHorizonDuration returns how soon a resource must be forecast full
+// for the forecast to be shown, zero when disabled
+func (c *Config) ForecastHorizonDuration() (time.Duration, error) {
+	return time.ParseDuration(c.ForecastHorizon)
 }
 
 // GetUpdateIntervalSeconds returns the update interval in seconds
@@ -51,16 +270,25 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %v", err)
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	// Start from the defaults so options missing from older files keep their default
+	cfg := Default()
+	if err := json.Unmarshal(data, cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file: %v", err)
+	}
 
-	return &cfg, nil
+	return cfg, nil
 }
 
-// Save saves configuration to file
+// Save saves configuration to file, unless it is the fallback of a file that
+// failed to load
 func (c *Config) Save() error {
+	if c.loadErr != nil {
+		return fmt.Errorf("not overwriting the configuration file, which failed to load: %v", c.loadErr)
+	}
+
 	configPath := getConfigPath()
 
 	// Create config directory if it doesn't exist
@@ -81,6 +309,41 @@ func (c *Config) Save() error {
 	return nil
 }
 
+// DataDir returns the directory of the on-disk metrics history
+func DataDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".p-monitor", "data")
+}
+
+// AlertJournalPath returns the file keeping the resolved, acknowledged and
+// snoozed alerts across restarts
+func AlertJournalPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".p-monitor", "alerts.json")
+}
+
+// APITokenPath returns the file holding the token that authorizes
+// configuration updates through the REST API
+func APITokenPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".p-monitor", "api-token")
+}
+
+// isLocalAddress reports whether a listener address is only reachable from
+// this machine: empty, a Unix socket or a TCP address on the loopback interface
+func isLocalAddress(address string) bool {
+	if address == "" || strings.HasPrefix(address, "unix:") {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 // getConfigPath returns the path to the configuration file
 func getConfigPath() string {
 	return filepath.Join(os.Getenv("HOME"), ".p-monitor", "config.json")
@@ -0,0 +1,73 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Commands understood by the control server
+const (
+	CommandStatus      = "status"
+	CommandInterval    = "interval"
+	CommandTemperature = "temperature"
+	CommandPause       = "pause"
+	CommandResume      = "resume"
+	CommandRefresh     = "refresh"
+	CommandReload      = "reload"
+	CommandRotateLogs  = "rotate-logs"
+)
+
+// clientTimeout bounds how long a client waits for the server to answer
+const clientTimeout = 30 * time.Second
+
+// Request is a single control command, sent as one line of JSON
+type Request struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// Response answers a request, sent as one line of JSON
+type Response struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// SocketPath returns the path of the control socket, placed in
+// $XDG_RUNTIME_DIR or, when unset, in a per-user file in the temp directory
+func SocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "p-monitor.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("p-monitor-%d.sock", os.Getuid()))
+}
+
+// Send sends a request to the control socket at path and returns the response
+func Send(path string, req Request) (*Response, error) {
+	conn, err := net.DialTimeout("unix", path, clientTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to p-monitor at %s: %v", path, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(clientTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	return &resp, nil
+}
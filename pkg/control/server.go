@@ -0,0 +1,243 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/monitor"
+	"p-monitor/pkg/types"
+)
+
+// maxRequestSize limits the length of a single request line
+const maxRequestSize = 64 * 1024
+
+// Server executes control commands against a running monitor
+type Server struct {
+	monitor *monitor.Monitor
+	config  *config.Config
+}
+
+// New creates a new control server
+func New(m *monitor.Monitor, cfg *config.Config) *Server {
+	return &Server{
+		monitor: m,
+		config:  cfg,
+	}
+}
+
+// Serve accepts connections on the socket at path until ctx is cancelled.
+// Each connection may send any number of requests, one JSON object per line.
+func (s *Server) Serve(ctx context.Context, path string) error {
+	listener, err := listen(path)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	logs.Info("Serving control socket on %s", path)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// serveConn answers the requests of a single connection
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	// Unblock the read below on shutdown
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxRequestSize)
+	encoder := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		var req Request
+		var resp Response
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp = Response{Error: fmt.Sprintf("invalid request: %v", err)}
+		} else {
+			resp = s.Execute(req)
+		}
+
+		if err := encoder.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// Execute runs a single command and returns its response
+func (s *Server) Execute(req Request) Response {
+	message, err := s.execute(req)
+	if err != nil {
+		logs.Error("Control command %q failed: %v", req.Command, err)
+		return Response{Error: err.Error()}
+	}
+
+	logs.Info("Control command %q: %s", req.Command, message)
+	return Response{OK: true, Message: message}
+}
+
+// execute dispatches a request to its command
+func (s *Server) execute(req Request) (string, error) {
+	switch req.Command {
+	case CommandStatus:
+		return s.status(), nil
+	case CommandInterval:
+		return s.setInterval(req.Args)
+	case CommandTemperature:
+		return s.setTemperatureUnit(req.Args)
+	case CommandPause:
+		s.monitor.Pause()
+		return "collection paused", nil
+	case CommandResume:
+		s.monitor.Resume()
+		return "collection resumed", nil
+	case CommandRefresh:
+		metrics := s.monitor.Refresh()
+		return "metrics refreshed at " + metrics.Updated.Format("15:04:05"), nil
+	case CommandReload:
+		running := s.config.Snapshot()
+		if err := s.config.Reload(); err != nil {
+			return "", fmt.Errorf("failed to reload configuration: %v", err)
+		}
+		s.monitor.Reconfigure()
+
+		if names := config.RestartRequired(running, s.config.Snapshot()); len(names) > 0 {
+			return "", fmt.Errorf("configuration reloaded, but %s only apply after a restart", strings.Join(names, ", "))
+		}
+		return "configuration reloaded", nil
+	case CommandRotateLogs:
+		if err := logs.Rotate(); err != nil {
+			return "", fmt.Errorf("failed to rotate logs: %v", err)
+		}
+		return "logs rotated", nil
+	default:
+		return "", fmt.Errorf("unknown command %q", req.Command)
+	}
+}
+
+// status describes the state of the running instance
+func (s *Server) status() string {
+	cfg := s.config.Snapshot()
+
+	state := "running"
+	if s.monitor.Paused() {
+		state = "paused"
+	}
+
+	updated := "never"
+	if metrics := s.monitor.GetMetrics(); metrics != nil {
+		updated = metrics.Updated.Format("15:04:05")
+	}
+
+	return fmt.Sprintf("%s, every %d %s, temperatures in %s, last update %s",
+		state, cfg.UpdateInterval, cfg.TimeUnit, cfg.TemperatureUnit, updated)
+}
+
+// setInterval handles "interval <value> [seconds|minutes]"
+func (s *Server) setInterval(args []string) (string, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return "", fmt.Errorf("usage: interval <value> [seconds|minutes]")
+	}
+
+	value, err := strconv.Atoi(args[0])
+	if err != nil {
+		return "", fmt.Errorf("invalid interval %q", args[0])
+	}
+
+	err = s.config.Update(func(cfg *config.Config) {
+		cfg.UpdateInterval = value
+		if len(args) == 2 {
+			cfg.TimeUnit = args[1]
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.monitor.Reconfigure()
+	cfg := s.config.Snapshot()
+	return fmt.Sprintf("update interval set to %d %s", cfg.UpdateInterval, cfg.TimeUnit), nil
+}
+
+// setTemperatureUnit handles "temperature [celsius|fahrenheit]", toggling the
+// unit when none is given
+func (s *Server) setTemperatureUnit(args []string) (string, error) {
+	if len(args) > 1 {
+		return "", fmt.Errorf("usage: temperature [celsius|fahrenheit]")
+	}
+
+	err := s.config.Update(func(cfg *config.Config) {
+		switch {
+		case len(args) == 1:
+			cfg.TemperatureUnit = args[0]
+		case cfg.TemperatureUnit == types.Fahrenheit:
+			cfg.TemperatureUnit = types.Celsius
+		default:
+			cfg.TemperatureUnit = types.Fahrenheit
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return "temperature unit set to " + s.config.Snapshot().TemperatureUnit, nil
+}
+
+// listen opens the control socket, refusing to replace the socket of another
+// running instance
+func listen(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("another instance is already listening on %s", path)
+	}
+
+	// Remove a socket left behind by a previous run
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale socket: %v", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the current user may control the instance
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %v", err)
+	}
+
+	return listener, nil
+}
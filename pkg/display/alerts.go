@@ -0,0 +1,100 @@
+package display
+
+import (
+	"fmt"
+	"time"
+
+	"p-monitor/pkg/alerting"
+	"p-monitor/pkg/types"
+
+	"fyne.io/fyne/v2"
+)
+
+// createAlertsMenuItem creates a submenu listing the pending and firing
+// alerts, each with acknowledge and snooze actions, and the latest resolved ones
+func (d *Display) createAlertsMenuItem() *fyne.MenuItem {
+	active := d.alerts.Alerts()
+	resolved := d.alerts.Resolved()
+
+	var items []*fyne.MenuItem
+	for _, alert := range active {
+		items = append(items, d.createAlertMenuItem(alert))
+	}
+	if len(active) == 0 {
+		item := fyne.NewMenuItem("No active alerts", nil)
+		item.Disabled = true
+		items = append(items, item)
+	}
+
+	if len(resolved) > 0 {
+		header := fyne.NewMenuItem("Resolved", nil)
+		header.Disabled = true
+		items = append(items, fyne.NewMenuItemSeparator(), header)
+		for _, alert := range resolved {
+			text := fmt.Sprintf("%s: %s at %s (since %s)",
+				alert.Rule, alert.Path, formatAlertTime(alert.ResolvedAt), formatAlertTime(alert.Since))
+			items = append(items, fyne.NewMenuItem(text, nil))
+		}
+	}
+
+	label := "Alerts"
+	if len(active) > 0 {
+		label = fmt.Sprintf("Alerts (%d)", len(active))
+	}
+	item := fyne.NewMenuItem(label, nil)
+	item.ChildMenu = fyne.NewMenu("Alerts", items...)
+	if firing(active) {
+		item.Icon = d.loadIcon("error-icon.png")
+	}
+	return item
+}
+
+// createAlertMenuItem creates the item of an active alert, with a submenu to
+// acknowledge or snooze it
+func (d *Display) createAlertMenuItem(alert alerting.Alert) *fyne.MenuItem {
+	unit := d.config.Snapshot().TemperatureUnit
+	text := fmt.Sprintf("%s: %s %s %s %s since %s",
+		alert.Rule, alert.Path,
+		types.FormatValue(alert.Path, alert.Value, unit), alert.Comparison,
+		types.FormatValue(alert.Path, alert.Threshold, unit), formatAlertTime(alert.Since))
+	if alert.State == alerting.StatePending {
+		text += " (pending)"
+	}
+	if !alert.SnoozedUntil.IsZero() {
+		text += fmt.Sprintf(" (snoozed until %s)", formatAlertTime(alert.SnoozedUntil))
+	}
+
+	acknowledge := fyne.NewMenuItem("Acknowledge", func() {
+		d.alerts.Acknowledge(alert.Rule, alert.Path)
+	})
+	acknowledge.Checked = alert.Acknowledged
+	acknowledge.Disabled = alert.Acknowledged
+
+	snooze := fyne.NewMenuItem("Snooze 1h", func() {
+		d.alerts.Snooze(alert.Rule, alert.Path, alerting.SnoozeDuration)
+	})
+
+	item := fyne.NewMenuItem(text, nil)
+	item.Checked = alert.Acknowledged
+	item.ChildMenu = fyne.NewMenu(alert.Rule, acknowledge, snooze)
+	return item
+}
+
+// firing reports whether any alert is firing and not acknowledged
+func firing(alerts []alerting.Alert) bool {
+	for _, alert := range alerts {
+		if alert.State == alerting.StateFiring && !alert.Acknowledged {
+			return true
+		}
+	}
+	return false
+}
+
+// formatAlertTime formats an alert timestamp, adding the date unless it is today
+func formatAlertTime(t time.Time) string {
+	now := time.Now()
+	if t.YearDay() == now.YearDay() && t.Year() == now.Year() {
+		return t.Format("15:04:05")
+	}
+	return t.Format("Jan 2 15:04")
+}
@@ -0,0 +1,294 @@
+package display
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+	"time"
+
+	"p-monitor/pkg/history"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	// chartMaxPoints limits how many points of a series are drawn, averaging the rest
+	chartMaxPoints = 300
+
+	// Margins around the plot area of a chart
+	chartLeftMargin   = 64
+	chartTopMargin    = 24
+	chartBottomMargin = 20
+)
+
+// chartSeries is a named line of a chart
+type chartSeries struct {
+	name   string
+	color  color.Color
+	points []history.Point
+}
+
+// lineChart is a widget drawing one or more series over a time range, with a
+// readout of the values under the mouse pointer
+type lineChart struct {
+	widget.BaseWidget
+
+	title  string
+	yMax   float64              // Fixed top of the value axis, zero to fit the data
+	format func(float64) string // Formats values for the axis and readouts
+	series []chartSeries
+	from   time.Time
+	to     time.Time
+
+	hovering bool
+	hoverX   float32
+}
+
+// newLineChart creates an empty chart
+func newLineChart(title string, yMax float64, format func(float64) string) *lineChart {
+	c := &lineChart{title: title, yMax: yMax, format: format}
+	c.ExtendBaseWidget(c)
+	return c
+}
+
+// SetData replaces the series and time range drawn
+func (c *lineChart) SetData(series []chartSeries, from, to time.Time) {
+	for i := range series {
+		series[i].points = thinPoints(series[i].points, chartMaxPoints)
+	}
+
+	c.series = series
+	c.from = from
+	c.to = to
+	c.Refresh()
+}
+
+// CreateRenderer implements fyne.Widget
+func (c *lineChart) CreateRenderer() fyne.WidgetRenderer {
+	return &lineChartRenderer{chart: c}
+}
+
+// MinSize implements fyne.CanvasObject
+func (c *lineChart) MinSize() fyne.Size {
+	return fyne.NewSize(480, 160)
+}
+
+// MouseIn implements desktop.Hoverable
+func (c *lineChart) MouseIn(event *desktop.MouseEvent) {
+	c.MouseMoved(event)
+}
+
+// MouseMoved implements desktop.Hoverable
+func (c *lineChart) MouseMoved(event *desktop.MouseEvent) {
+	c.hovering = true
+	c.hoverX = event.Position.X
+	c.Refresh()
+}
+
+// MouseOut implements desktop.Hoverable
+func (c *lineChart) MouseOut() {
+	c.hovering = false
+	c.Refresh()
+}
+
+// valueRange returns the bottom and top of the value axis
+func (c *lineChart) valueRange() (float64, float64) {
+	low, high := 0.0, c.yMax
+	if c.yMax > 0 {
+		return low, high
+	}
+
+	for _, s := range c.series {
+		for _, point := range s.points {
+			low = math.Min(low, point.Value)
+			high = math.Max(high, point.Value)
+		}
+	}
+	if high <= low {
+		high = low + 1
+	}
+	// Leave some room above the highest value
+	return low, high + (high-low)*0.1
+}
+
+// lineChartRenderer draws a lineChart, rebuilding its objects on every refresh
+type lineChartRenderer struct {
+	chart   *lineChart
+	size    fyne.Size
+	objects []fyne.CanvasObject
+}
+
+// Layout implements fyne.WidgetRenderer
+func (r *lineChartRenderer) Layout(size fyne.Size) {
+	r.size = size
+	r.build()
+}
+
+// MinSize implements fyne.WidgetRenderer
+func (r *lineChartRenderer) MinSize() fyne.Size {
+	return r.chart.MinSize()
+}
+
+// Refresh implements fyne.WidgetRenderer
+func (r *lineChartRenderer) Refresh() {
+	r.build()
+	canvas.Refresh(r.chart)
+}
+
+// Objects implements fyne.WidgetRenderer
+func (r *lineChartRenderer) Objects() []fyne.CanvasObject {
+	return r.objects
+}
+
+// Destroy implements fyne.WidgetRenderer
+func (r *lineChartRenderer) Destroy() {}
+
+// build creates the canvas objects for the current data and size
+func (r *lineChartRenderer) build() {
+	c := r.chart
+	foreground := theme.Color(theme.ColorNameForeground)
+	muted := theme.Color(theme.ColorNameDisabled)
+
+	plotX, plotY := float32(chartLeftMargin), float32(chartTopMargin)
+	plotW := r.size.Width - chartLeftMargin - 8
+	plotH := r.size.Height - chartTopMargin - chartBottomMargin
+	if plotW <= 0 || plotH <= 0 {
+		r.objects = nil
+		return
+	}
+
+	var objects []fyne.CanvasObject
+
+	title := canvas.NewText(c.title, foreground)
+	title.TextStyle = fyne.TextStyle{Bold: true}
+	title.Move(fyne.NewPos(0, 0))
+	objects = append(objects, title)
+
+	// Axes and their labels
+	low, high := c.valueRange()
+	objects = append(objects,
+		newLine(plotX, plotY, plotX, plotY+plotH, muted),
+		newLine(plotX, plotY+plotH, plotX+plotW, plotY+plotH, muted),
+		newText(c.format(high), muted, 0, plotY-8),
+		newText(c.format(low), muted, 0, plotY+plotH-16),
+		newText(c.from.Format(timeLayout(c.from, c.to)), muted, plotX, plotY+plotH+2),
+	)
+	end := newText(c.to.Format(timeLayout(c.from, c.to)), muted, 0, plotY+plotH+2)
+	end.Move(fyne.NewPos(plotX+plotW-end.MinSize().Width, plotY+plotH+2))
+	objects = append(objects, end)
+
+	span := c.to.Sub(c.from).Seconds()
+	toX := func(t time.Time) float32 {
+		return plotX + float32(t.Sub(c.from).Seconds()/span)*plotW
+	}
+	toY := func(value float64) float32 {
+		return plotY + plotH - float32((value-low)/(high-low))*plotH
+	}
+
+	hasData := false
+	for _, s := range c.series {
+		for i := 1; i < len(s.points); i++ {
+			previous, point := s.points[i-1], s.points[i]
+			line := newLine(toX(previous.Time), toY(previous.Value), toX(point.Time), toY(point.Value), s.color)
+			line.StrokeWidth = 1.5
+			objects = append(objects, line)
+		}
+		hasData = hasData || len(s.points) > 0
+	}
+
+	if !hasData {
+		objects = append(objects, newText("No data for this range", muted, plotX+8, plotY+plotH/2-8))
+	}
+
+	// Readout of the values closest to the pointer
+	if c.hovering && hasData && c.hoverX >= plotX && c.hoverX <= plotX+plotW {
+		at := c.from.Add(time.Duration(float64(c.hoverX-plotX) / float64(plotW) * span * float64(time.Second)))
+
+		parts := []string{at.Format(timeLayout(c.from, c.to))}
+		for _, s := range c.series {
+			if point, ok := nearestPoint(s.points, at); ok {
+				parts = append(parts, fmt.Sprintf("%s %s", s.name, c.format(point.Value)))
+			}
+		}
+
+		objects = append(objects, newLine(c.hoverX, plotY, c.hoverX, plotY+plotH, muted))
+		readout := newText(strings.Join(parts, "   "), foreground, 0, 0)
+		readout.Move(fyne.NewPos(r.size.Width-readout.MinSize().Width, 0))
+		objects = append(objects, readout)
+	}
+
+	r.objects = objects
+}
+
+// newLine creates a line between two points
+func newLine(x1, y1, x2, y2 float32, lineColor color.Color) *canvas.Line {
+	line := canvas.NewLine(lineColor)
+	line.Position1 = fyne.NewPos(x1, y1)
+	line.Position2 = fyne.NewPos(x2, y2)
+	return line
+}
+
+// newText creates a small text at a position
+func newText(text string, textColor color.Color, x, y float32) *canvas.Text {
+	t := canvas.NewText(text, textColor)
+	t.TextSize = theme.CaptionTextSize()
+	t.Move(fyne.NewPos(x, y))
+	t.Resize(t.MinSize())
+	return t
+}
+
+// timeLayout returns the time format suiting the length of a range
+func timeLayout(from, to time.Time) string {
+	switch span := to.Sub(from); {
+	case span <= time.Hour:
+		return "15:04:05"
+	case span <= 24*time.Hour:
+		return "15:04"
+	default:
+		return "Jan 2 15:04"
+	}
+}
+
+// nearestPoint returns the point closest in time to at
+func nearestPoint(points []history.Point, at time.Time) (history.Point, bool) {
+	var nearest history.Point
+	best := time.Duration(math.MaxInt64)
+	for _, point := range points {
+		distance := point.Time.Sub(at)
+		if distance < 0 {
+			distance = -distance
+		}
+		if distance < best {
+			nearest, best = point, distance
+		}
+	}
+	return nearest, best != time.Duration(math.MaxInt64)
+}
+
+// thinPoints averages consecutive points so that at most n remain
+func thinPoints(points []history.Point, n int) []history.Point {
+	if len(points) <= n {
+		return points
+	}
+
+	size := (len(points) + n - 1) / n
+	thinned := make([]history.Point, 0, n)
+	for start := 0; start < len(points); start += size {
+		group := points[start:min(start+size, len(points))]
+
+		sum := 0.0
+		for _, point := range group {
+			sum += point.Value
+		}
+		thinned = append(thinned, history.Point{
+			Time:  group[len(group)/2].Time,
+			Value: sum / float64(len(group)),
+		})
+	}
+	return thinned
+}
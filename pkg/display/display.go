@@ -2,13 +2,16 @@ package display
 
 import (
 	"fmt"
-	"path/filepath"
 	"strings"
 	"time"
 
+	"p-monitor/internal/assets"
 	"p-monitor/internal/logs"
+	"p-monitor/pkg/alerting"
 	"p-monitor/pkg/config"
+	"p-monitor/pkg/forecast"
 	"p-monitor/pkg/monitor"
+	"p-monitor/pkg/store"
 	"p-monitor/pkg/types"
 
 	"fyne.io/fyne/v2"
@@ -16,6 +19,14 @@ import (
 	"fyne.io/fyne/v2/storage"
 )
 
+const (
+	// maxServiceMenuItems limits how many services are listed in the Services submenu
+	maxServiceMenuItems = 10
+
+	// maxContainerMenuItems limits how many containers are listed in the Containers submenu
+	maxContainerMenuItems = 10
+)
+
 // Display handles the system tray display
 type Display struct {
 	app       desktop.App
@@ -23,6 +34,13 @@ type Display struct {
 	config    *config.Config
 	menu      *fyne.Menu
 	menuItems map[string]*fyne.MenuItem
+	store     *store.Store         // Optional, serves history older than the monitor keeps
+	forecast  *forecast.Forecaster // Optional, adds "full in" to the disk and memory items
+	alerts    *alerting.Engine     // Optional, adds the Alerts submenu
+	history   *historyWindow       // Open history window, if any
+	trayIcon  []byte               // PNG of the rendered tray icon, nil while the static one is shown
+
+	exportWindow fyne.Window // Open export window, if any
 }
 
 // New creates a new display instance
@@ -35,6 +53,21 @@ func New(app desktop.App, monitor *monitor.Monitor, cfg *config.Config) *Display
 	}
 }
 
+// SetStore sets the on-disk store the history window reads older samples from
+func (d *Display) SetStore(s *store.Store) {
+	d.store = s
+}
+
+// SetForecaster sets the forecaster of the disk and memory items
+func (d *Display) SetForecaster(f *forecast.Forecaster) {
+	d.forecast = f
+}
+
+// SetAlerts sets the alerting engine listed in the Alerts submenu
+func (d *Display) SetAlerts(e *alerting.Engine) {
+	d.alerts = e
+}
+
 // Start starts the display system
 func (d *Display) Start() {
 	logs.Info("Starting display system")
@@ -51,8 +84,7 @@ func (d *Display) Start() {
 
 // setSystemTrayIcon sets the system tray icon
 func (d *Display) setSystemTrayIcon() {
-	iconPath := filepath.Join("assets", "icon.png")
-	iconURI := storage.NewFileURI(iconPath)
+	iconURI := storage.NewFileURI(assets.Path("icon.png"))
 	icon, err := storage.LoadResourceFromURI(iconURI)
 	if err != nil {
 		logs.Error("Failed to load system tray icon: %v", err)
@@ -103,6 +135,9 @@ func (d *Display) updateMenu() {
 
 	// Recreate menu items with updated data
 	d.recreateMenuItems(metrics)
+
+	// Redraw the tray icon from the same data
+	d.updateTrayIcon(metrics)
 }
 
 // recreateMenuItems recreates the menu with updated metrics
@@ -112,9 +147,16 @@ func (d *Display) recreateMenuItems(metrics *types.SystemMetrics) {
 	d.menuItems = make(map[string]*fyne.MenuItem)
 
 	// Add disk metrics
-	d.menuItems["disk"] = d.createDiskMenuItem(metrics.Disk)
+	d.menuItems["disk"] = d.createDiskMenuItem(metrics.Disk, metrics.Mounts)
 	d.menu.Items = append(d.menu.Items, d.menuItems["disk"])
 
+	// Add drive health next to the disk usage
+	for _, drive := range metrics.Drives {
+		key := "drive-" + drive.Device
+		d.menuItems[key] = d.createDriveMenuItem(drive)
+		d.menu.Items = append(d.menu.Items, d.menuItems[key])
+	}
+
 	// Add memory metrics
 	d.menuItems["memory"] = d.createMemoryMenuItem(metrics.Memory)
 	d.menu.Items = append(d.menu.Items, d.menuItems["memory"])
@@ -130,6 +172,30 @@ func (d *Display) recreateMenuItems(metrics *types.SystemMetrics) {
 		d.menu.Items = append(d.menu.Items, d.menuItems[key])
 	}
 
+	// Add services submenu
+	if len(metrics.Services) > 0 {
+		d.menuItems["services"] = d.createServicesMenuItem("Services", metrics.Services)
+		d.menu.Items = append(d.menu.Items, d.menuItems["services"])
+	}
+
+	// Add slices submenu, apart from the services they include
+	if len(metrics.Slices) > 0 {
+		d.menuItems["slices"] = d.createServicesMenuItem("Slices", metrics.Slices)
+		d.menu.Items = append(d.menu.Items, d.menuItems["slices"])
+	}
+
+	// Add containers submenu
+	if len(metrics.Containers) > 0 {
+		d.menuItems["containers"] = d.createContainersMenuItem(metrics.Containers)
+		d.menu.Items = append(d.menu.Items, d.menuItems["containers"])
+	}
+
+	// Add alerts submenu
+	if d.alerts != nil {
+		d.menuItems["alerts"] = d.createAlertsMenuItem()
+		d.menu.Items = append(d.menu.Items, d.menuItems["alerts"])
+	}
+
 	// Add separator
 	d.menu.Items = append(d.menu.Items, fyne.NewMenuItemSeparator())
 
@@ -140,8 +206,9 @@ func (d *Display) recreateMenuItems(metrics *types.SystemMetrics) {
 	d.app.SetSystemTrayMenu(d.menu)
 }
 
-// createDiskMenuItem creates a disk metrics menu item
-func (d *Display) createDiskMenuItem(disk *types.DiskMetrics) *fyne.MenuItem {
+// createDiskMenuItem creates a disk metrics menu item, listing the other
+// mounts in a submenu
+func (d *Display) createDiskMenuItem(disk *types.DiskMetrics, mounts []*types.DiskMetrics) *fyne.MenuItem {
 	var text string
 	var icon fyne.Resource
 
@@ -149,13 +216,74 @@ func (d *Display) createDiskMenuItem(disk *types.DiskMetrics) *fyne.MenuItem {
 		text = "HDD: n/a"
 		icon = d.loadIcon("error-icon.png")
 	} else {
-		totalGB := float64(disk.Total) / (1024 * 1024 * 1024)
-		text = fmt.Sprintf("HDD: %.1fGB (%.1f%%)", totalGB, disk.UsedPercent)
+		text = fmt.Sprintf("HDD: %s (%.1f%%)%s", types.FormatGigabytes(disk.Total), disk.UsedPercent, d.fullIn(forecast.ResourceDisk))
 		icon = d.loadIcon("drive-icon.png")
 	}
 
 	item := fyne.NewMenuItem(text, nil)
 	item.Icon = icon
+
+	if len(mounts) > 0 {
+		var items []*fyne.MenuItem
+		for _, mount := range mounts {
+			text := fmt.Sprintf("%s: n/a", mount.Mount)
+			if mount.Error == "" {
+				text = fmt.Sprintf("%s: %s (%.1f%%)%s", mount.Mount, types.FormatGigabytes(mount.Total),
+					mount.UsedPercent, d.fullIn(forecast.DiskResource(mount.Mount)))
+			}
+			items = append(items, fyne.NewMenuItem(text, nil))
+		}
+		item.ChildMenu = fyne.NewMenu("Mounts", items...)
+	}
+	return item
+}
+
+// fullIn returns the " full in ~3h" suffix of a resource forecast to be full
+// within the horizon, or nothing
+func (d *Display) fullIn(resource string) string {
+	if d.forecast == nil {
+		return ""
+	}
+	f, ok := d.forecast.Within(resource)
+	if !ok {
+		return ""
+	}
+	return " full in " + forecast.FormatDuration(f.FullIn)
+}
+
+// createDriveMenuItem creates a drive health menu item, listing warnings in a submenu
+func (d *Display) createDriveMenuItem(drive *types.DriveHealth) *fyne.MenuItem {
+	label := fmt.Sprintf("%s %s", strings.ToUpper(drive.Type), drive.Device)
+
+	var details []string
+	if types.HasTemperature(drive.Temperature) {
+		details = append(details, d.formatTemperature(drive.Temperature))
+	}
+	if drive.Error == "" && drive.Type == "nvme" {
+		details = append(details, fmt.Sprintf("%.0f%% used", drive.PercentageUsed))
+	}
+	if len(drive.Warnings) > 0 {
+		details = append(details, fmt.Sprintf("⚠ %d warnings", len(drive.Warnings)))
+	}
+
+	text := fmt.Sprintf("%s: n/a", label)
+	if len(details) > 0 {
+		text = fmt.Sprintf("%s: %s", label, strings.Join(details, ", "))
+	}
+
+	item := fyne.NewMenuItem(text, nil)
+	if len(drive.Warnings) > 0 {
+		item.Icon = d.loadIcon("error-icon.png")
+
+		var warnings []*fyne.MenuItem
+		for _, warning := range drive.Warnings {
+			warnings = append(warnings, fyne.NewMenuItem(warning, nil))
+		}
+		item.ChildMenu = fyne.NewMenu(label, warnings...)
+	} else {
+		item.Icon = d.loadIcon("drive-icon.png")
+	}
+
 	return item
 }
 
@@ -168,8 +296,7 @@ func (d *Display) createMemoryMenuItem(memory *types.MemoryMetrics) *fyne.MenuIt
 		text = "RAM: n/a"
 		icon = d.loadIcon("error-icon.png")
 	} else {
-		totalGB := float64(memory.Total) / (1024 * 1024 * 1024)
-		text = fmt.Sprintf("RAM: %.1fGB (%.1f%%)", totalGB, memory.UsedPercent)
+		text = fmt.Sprintf("RAM: %s (%.1f%%)%s", types.FormatGigabytes(memory.Total), memory.UsedPercent, d.fullIn(forecast.ResourceMemory))
 		icon = d.loadIcon("drive-icon.png") // Using drive icon for memory for now
 	}
 
@@ -187,8 +314,8 @@ func (d *Display) createCPUMenuItem(cpu *types.CPUMetrics) *fyne.MenuItem {
 		text = "CPU: n/a"
 		icon = d.loadIcon("error-icon.png")
 	} else {
-		if cpu.Temperature > 0 {
-			text = fmt.Sprintf("CPU: %.1f%% (%.1f°C)", cpu.UsagePercent, cpu.Temperature)
+		if types.HasTemperature(cpu.Temperature) {
+			text = fmt.Sprintf("CPU: %.1f%% (%s)", cpu.UsagePercent, d.formatTemperature(cpu.Temperature))
 		} else {
 			text = fmt.Sprintf("CPU: %.1f%%", cpu.UsagePercent)
 		}
@@ -210,8 +337,8 @@ func (d *Display) createGPUMenuItem(gpu *types.GPUMetrics, index int) *fyne.Menu
 	if gpu.Error != "" {
 		text = fmt.Sprintf("%s: n/a", gpuLabel)
 	} else {
-		if gpu.Temperature > 0 {
-			text = fmt.Sprintf("%s: %.1f%% %.1f°C", gpuLabel, gpu.UsagePercent, gpu.Temperature)
+		if types.HasTemperature(gpu.Temperature) {
+			text = fmt.Sprintf("%s: %.1f%% %s", gpuLabel, gpu.UsagePercent, d.formatTemperature(gpu.Temperature))
 		} else {
 			text = fmt.Sprintf("%s: %.1f%%", gpuLabel, gpu.UsagePercent)
 		}
@@ -241,17 +368,80 @@ func (d *Display) getSimplifiedGPULabel(gpu *types.GPUMetrics, index int) string
 	}
 }
 
+// createServicesMenuItem creates a submenu listing the most consuming services or slices
+func (d *Display) createServicesMenuItem(label string, services []*types.ServiceMetrics) *fyne.MenuItem {
+	// Services are already sorted by consumption
+	if len(services) > maxServiceMenuItems {
+		services = services[:maxServiceMenuItems]
+	}
+
+	var items []*fyne.MenuItem
+	for _, service := range services {
+		text := fmt.Sprintf("%s: %.1f%% %s (R %s/s W %s/s)",
+			service.Name,
+			service.CPUPercent,
+			types.FormatBytes(float64(service.MemoryBytes)),
+			types.FormatBytes(service.IOReadRate),
+			types.FormatBytes(service.IOWriteRate),
+		)
+		items = append(items, fyne.NewMenuItem(text, nil))
+	}
+
+	item := fyne.NewMenuItem(label, nil)
+	item.ChildMenu = fyne.NewMenu(label, items...)
+	return item
+}
+
+// createContainersMenuItem creates a submenu listing the most consuming containers
+func (d *Display) createContainersMenuItem(containers []*types.ContainerMetrics) *fyne.MenuItem {
+	// Containers are already sorted by consumption
+	if len(containers) > maxContainerMenuItems {
+		containers = containers[:maxContainerMenuItems]
+	}
+
+	var items []*fyne.MenuItem
+	for _, container := range containers {
+		text := fmt.Sprintf("%s (%s): %.1f%% %s (RX %s/s TX %s/s)",
+			container.Name,
+			container.Runtime,
+			container.CPUPercent,
+			types.FormatBytes(float64(container.MemoryBytes)),
+			types.FormatBytes(container.NetRxRate),
+			types.FormatBytes(container.NetTxRate),
+		)
+		items = append(items, fyne.NewMenuItem(text, nil))
+	}
+
+	item := fyne.NewMenuItem("Containers", nil)
+	item.ChildMenu = fyne.NewMenu("Containers", items...)
+	return item
+}
+
 // addConfigMenuItems adds configuration menu items
 func (d *Display) addConfigMenuItems() {
+	// History charts
+	historyItem := fyne.NewMenuItem("Show history", func() {
+		d.showHistoryWindow()
+	})
+	d.menu.Items = append(d.menu.Items, historyItem)
+
+	// Export of the history to a file
+	exportItem := fyne.NewMenuItem("Export…", func() {
+		d.showExportWindow()
+	})
+	d.menu.Items = append(d.menu.Items, exportItem)
+
+	cfg := d.config.Snapshot()
+
 	// Update interval
-	intervalText := fmt.Sprintf("Update: %d %s", d.config.UpdateInterval, d.config.TimeUnit)
+	intervalText := fmt.Sprintf("Update: %d %s", cfg.UpdateInterval, cfg.TimeUnit)
 	intervalItem := fyne.NewMenuItem(intervalText, func() {
 		d.showIntervalDialog()
 	})
 	d.menu.Items = append(d.menu.Items, intervalItem)
 
 	// Temperature unit
-	tempText := fmt.Sprintf("Temperature: %s", strings.Title(d.config.TemperatureUnit))
+	tempText := fmt.Sprintf("Temperature: %s", strings.Title(cfg.TemperatureUnit))
 	tempItem := fyne.NewMenuItem(tempText, func() {
 		d.toggleTemperatureUnit()
 	})
@@ -260,8 +450,7 @@ func (d *Display) addConfigMenuItems() {
 
 // loadIcon loads an icon from the assets directory
 func (d *Display) loadIcon(filename string) fyne.Resource {
-	iconPath := filepath.Join("assets", filename)
-	iconURI := storage.NewFileURI(iconPath)
+	iconURI := storage.NewFileURI(assets.Path(filename))
 	icon, err := storage.LoadResourceFromURI(iconURI)
 	if err != nil {
 		logs.Error("Failed to load icon %s: %v", filename, err)
@@ -288,24 +477,31 @@ func (d *Display) updateLoop() {
 func (d *Display) showIntervalDialog() {
 	// This would show a dialog to change the interval
 	// For now, we'll just toggle between some common values
-	if d.config.TimeUnit == "seconds" {
-		if d.config.UpdateInterval >= 60 {
-			d.config.UpdateInterval = 1
-			d.config.TimeUnit = "minutes"
+	err := d.config.Update(func(cfg *config.Config) {
+		if cfg.TimeUnit == "seconds" {
+			if cfg.UpdateInterval >= 60 {
+				cfg.UpdateInterval = 1
+				cfg.TimeUnit = "minutes"
+			} else {
+				cfg.UpdateInterval += 5
+			}
 		} else {
-			d.config.UpdateInterval += 5
-		}
-	} else {
-		if d.config.UpdateInterval >= 30 {
-			d.config.UpdateInterval = 5
-			d.config.TimeUnit = "seconds"
-		} else {
-			d.config.UpdateInterval += 5
+			if cfg.UpdateInterval >= 30 {
+				cfg.UpdateInterval = 5
+				cfg.TimeUnit = "seconds"
+			} else {
+				cfg.UpdateInterval += 5
+			}
 		}
+	})
+	if err != nil {
+		logs.Error("Failed to save configuration: %v", err)
 	}
+	cfg := d.config.Snapshot()
+	logs.Info("Updated interval to %d %s", cfg.UpdateInterval, cfg.TimeUnit)
 
-	d.config.Save()
-	logs.Info("Updated interval to %d %s", d.config.UpdateInterval, d.config.TimeUnit)
+	// Apply the new interval to the running monitor
+	d.monitor.Reconfigure()
 
 	// Trigger menu update to show new configuration
 	d.updateMenu()
@@ -313,15 +509,23 @@ func (d *Display) showIntervalDialog() {
 
 // toggleTemperatureUnit toggles between Celsius and Fahrenheit
 func (d *Display) toggleTemperatureUnit() {
-	if d.config.TemperatureUnit == "celsius" {
-		d.config.TemperatureUnit = "fahrenheit"
-	} else {
-		d.config.TemperatureUnit = "celsius"
+	err := d.config.Update(func(cfg *config.Config) {
+		if cfg.TemperatureUnit == types.Celsius {
+			cfg.TemperatureUnit = types.Fahrenheit
+		} else {
+			cfg.TemperatureUnit = types.Celsius
+		}
+	})
+	if err != nil {
+		logs.Error("Failed to save configuration: %v", err)
 	}
-
-	d.config.Save()
-	logs.Info("Updated temperature unit to %s", d.config.TemperatureUnit)
+	logs.Info("Updated temperature unit to %s", d.config.Snapshot().TemperatureUnit)
 
 	// Trigger menu update to show new configuration
 	d.updateMenu()
 }
+
+// formatTemperature formats a Celsius temperature in the configured unit
+func (d *Display) formatTemperature(celsius float64) string {
+	return types.FormatTemperature(celsius, d.config.Snapshot().TemperatureUnit)
+}
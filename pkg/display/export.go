@@ -0,0 +1,99 @@
+package display
+
+import (
+	"fmt"
+	"time"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/export"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showExportWindow opens a window exporting the history to a file, or focuses
+// it when already open
+func (d *Display) showExportWindow() {
+	if d.exportWindow != nil {
+		d.exportWindow.RequestFocus()
+		return
+	}
+
+	window := fyne.CurrentApp().NewWindow("p-monitor export")
+
+	labels := make([]string, len(historyRanges))
+	for i, r := range historyRanges {
+		labels[i] = r.label
+	}
+	rangeSelect := widget.NewRadioGroup(labels, nil)
+	rangeSelect.Horizontal = true
+	rangeSelect.Required = true
+	rangeSelect.SetSelected(historyRanges[1].label)
+
+	formatSelect := widget.NewSelect(export.Formats, nil)
+	formatSelect.SetSelected(export.FormatCSV)
+
+	metricsEntry := widget.NewEntry()
+	metricsEntry.SetPlaceHolder("All metrics, or e.g. cpu,memory.used_percent")
+
+	save := widget.NewButton("Export…", func() {
+		var span time.Duration
+		for _, r := range historyRanges {
+			if r.label == rangeSelect.Selected {
+				span = r.span
+			}
+		}
+		format := formatSelect.Selected
+		selectors := export.ParseSelectors(metricsEntry.Text)
+
+		fileDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if writer == nil {
+				return // Cancelled
+			}
+
+			go d.exportHistory(writer, window, time.Now().Add(-span), format, selectors)
+		}, window)
+		fileDialog.SetFileName(fmt.Sprintf("p-monitor-%s.%s", time.Now().Format("20060102-150405"), format))
+		fileDialog.Show()
+	})
+
+	form := widget.NewForm(
+		widget.NewFormItem("Range", rangeSelect),
+		widget.NewFormItem("Format", formatSelect),
+		widget.NewFormItem("Metrics", metricsEntry),
+	)
+	window.SetContent(container.NewVBox(form, save))
+	window.Resize(fyne.NewSize(480, 0))
+	window.SetOnClosed(func() {
+		d.exportWindow = nil
+	})
+
+	d.exportWindow = window
+	window.Show()
+}
+
+// exportHistory writes the samples since from to writer, reporting the outcome in window
+func (d *Display) exportHistory(writer fyne.URIWriteCloser, window fyne.Window, from time.Time, format string, selectors []string) {
+	samples := d.historySamples(from)
+
+	err := export.Write(writer, format, samples, selectors)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+
+	fyne.Do(func() {
+		if err != nil {
+			logs.Error("Failed to export history: %v", err)
+			dialog.ShowError(err, window)
+			return
+		}
+		logs.Info("Exported %d samples to %s", len(samples), writer.URI().Path())
+		dialog.ShowInformation("Export", fmt.Sprintf("Exported %d samples.", len(samples)), window)
+	})
+}
@@ -0,0 +1,304 @@
+package display
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+	"time"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/history"
+	"p-monitor/pkg/store"
+	"p-monitor/pkg/types"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// historyRefreshInterval is how often a history window showing data older
+// than the in-memory history reloads it from the store
+const historyRefreshInterval = time.Minute
+
+// historyRange is a time range selectable in the history window
+type historyRange struct {
+	label string
+	span  time.Duration
+}
+
+// historyRanges are the time ranges selectable in the history window
+var historyRanges = []historyRange{
+	{"5m", 5 * time.Minute},
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+}
+
+// historyWindow shows line charts of the recorded metrics
+type historyWindow struct {
+	display *Display
+	window  fyne.Window
+	spans   chan time.Duration // Range selections, handled by run
+	stop    chan struct{}
+
+	cpu         *lineChart
+	temperature *lineChart
+	memory      *lineChart
+	disk        *lineChart
+	network     *lineChart
+	gpus        []*lineChart // Usage and temperature of every GPU
+	gpuBox      *fyne.Container
+}
+
+// ShowHistory opens the history window from any goroutine
+func (d *Display) ShowHistory() {
+	fyne.Do(d.showHistoryWindow)
+}
+
+// showHistoryWindow opens the history window, or focuses it when already open
+func (d *Display) showHistoryWindow() {
+	if d.history != nil {
+		d.history.window.RequestFocus()
+		return
+	}
+
+	w := &historyWindow{
+		display:     d,
+		window:      fyne.CurrentApp().NewWindow("p-monitor history"),
+		spans:       make(chan time.Duration, 1),
+		stop:        make(chan struct{}),
+		cpu:         newLineChart("CPU usage", 100, formatPercentValue),
+		temperature: newLineChart("CPU temperature", 0, formatTemperatureValue(d.config.Snapshot().TemperatureUnit)),
+		memory:      newLineChart("RAM usage", 100, formatPercentValue),
+		disk:        newLineChart("Disk usage", 100, formatPercentValue),
+		network:     newLineChart("Network traffic", 0, formatRateValue),
+		gpuBox:      container.NewVBox(),
+	}
+
+	ranges, note := d.availableRanges()
+	labels := make([]string, len(ranges))
+	for i, r := range ranges {
+		labels[i] = r.label
+	}
+	selector := widget.NewRadioGroup(labels, func(label string) {
+		for _, r := range ranges {
+			if r.label == label {
+				w.selectSpan(r.span)
+			}
+		}
+	})
+	selector.Horizontal = true
+	selector.Required = true
+
+	var top fyne.CanvasObject = selector
+	if note != "" {
+		top = container.NewVBox(selector, widget.NewLabel(note))
+	}
+
+	charts := container.NewVBox(w.cpu, w.temperature, w.memory, w.disk, w.network, w.gpuBox)
+	w.window.SetContent(container.NewBorder(top, nil, nil, nil, container.NewVScroll(charts)))
+	w.window.Resize(fyne.NewSize(720, 640))
+	w.window.SetOnClosed(func() {
+		close(w.stop)
+		d.history = nil
+	})
+
+	d.history = w
+	selector.SetSelected(historyRanges[0].label)
+	w.window.Show()
+
+	go w.run()
+}
+
+// availableRanges returns the history ranges that can be shown. Without a
+// store, ranges longer than the in-memory history are left out and note says
+// why; the shortest range is always kept.
+func (d *Display) availableRanges() (ranges []historyRange, note string) {
+	if d.store != nil {
+		return historyRanges, ""
+	}
+
+	cfg := d.config.Snapshot()
+	capacity := time.Duration(cfg.HistorySize*cfg.UpdateInterval) * time.Second
+	var missing []string
+	for i, r := range historyRanges {
+		if i == 0 || r.span <= capacity {
+			ranges = append(ranges, r)
+		} else {
+			missing = append(missing, r.label)
+		}
+	}
+	if len(missing) == 0 {
+		return ranges, ""
+	}
+
+	reason := "the on-disk history is disabled by data_max_size_mb or failed to open"
+	if d.monitor.Replaying() {
+		reason = "the on-disk history is not used while replaying"
+	}
+	return ranges, fmt.Sprintf("%s unavailable: %s and only %v is kept in memory",
+		strings.Join(missing, " and "), reason, capacity)
+}
+
+// run reloads the charts when the range changes, after every collection
+// while the range is covered by the in-memory history, and periodically
+// otherwise, until the window is closed
+func (w *historyWindow) run() {
+	updates := w.display.monitor.Subscribe()
+	defer w.display.monitor.Unsubscribe(updates)
+
+	ticker := time.NewTicker(historyRefreshInterval)
+	defer ticker.Stop()
+
+	span := historyRanges[0].span
+	for {
+		select {
+		case span = <-w.spans:
+			w.load(span)
+		case <-updates:
+			if w.display.inMemory(time.Now().Add(-span)) {
+				w.load(span)
+			}
+		case <-ticker.C:
+			if !w.display.inMemory(time.Now().Add(-span)) {
+				w.load(span)
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// inMemory reports whether the in-memory history holds every sample since from
+func (d *Display) inMemory(from time.Time) bool {
+	oldest := d.monitor.History().Oldest()
+	return !oldest.IsZero() && !oldest.After(from)
+}
+
+// historySamples returns the samples since from, taken from the in-memory
+// history when it covers the range and from the store otherwise
+func (d *Display) historySamples(from time.Time) []history.Sample {
+	memory := d.monitor.History()
+	if d.store == nil || d.inMemory(from) {
+		return memory.Range(from, time.Time{})
+	}
+
+	records, err := d.store.Range(from, time.Time{})
+	if err != nil {
+		logs.Error("Failed to read history from the store: %v", err)
+		return memory.Range(from, time.Time{})
+	}
+	return store.Samples(records)
+}
+
+// load reads the samples of the last span and redraws every chart
+func (w *historyWindow) load(span time.Duration) {
+	to := time.Now()
+	from := to.Add(-span)
+	samples := w.display.historySamples(from)
+	unit := w.display.config.Snapshot().TemperatureUnit
+
+	series := func(name, path string, seriesColor color.Color) chartSeries {
+		return chartSeries{name: name, color: seriesColor, points: seriesOf(samples, path)}
+	}
+	primary := theme.Color(theme.ColorNamePrimary)
+	secondary := theme.Color(theme.ColorNameSuccess)
+
+	gpuCount := 0
+	for _, sample := range samples {
+		for gpuCount < maxGPUs {
+			if _, ok := sample.Values[gpuPath(gpuCount, "usage_percent")]; !ok {
+				break
+			}
+			gpuCount++
+		}
+	}
+
+	fyne.Do(func() {
+		w.cpu.SetData([]chartSeries{series("CPU", "cpu.usage_percent", primary)}, from, to)
+		w.temperature.format = formatTemperatureValue(unit)
+		w.temperature.SetData([]chartSeries{series("CPU", "cpu.temperature", primary)}, from, to)
+		w.memory.SetData([]chartSeries{series("RAM", "memory.used_percent", primary)}, from, to)
+		w.disk.SetData([]chartSeries{series("Disk", "disk.used_percent", primary)}, from, to)
+		w.network.SetData([]chartSeries{
+			series("RX", "network.rx_rate", primary),
+			series("TX", "network.tx_rate", secondary),
+		}, from, to)
+
+		// Add charts for GPUs that appeared in the range
+		for len(w.gpus) < gpuCount*2 {
+			index := len(w.gpus) / 2
+			usage := newLineChart(fmt.Sprintf("GPU %d usage", index), 100, formatPercentValue)
+			temperature := newLineChart(fmt.Sprintf("GPU %d temperature", index), 0, formatTemperatureValue(unit))
+			w.gpus = append(w.gpus, usage, temperature)
+			w.gpuBox.Add(usage)
+			w.gpuBox.Add(temperature)
+		}
+
+		for i := 0; i < len(w.gpus); i += 2 {
+			index := i / 2
+			w.gpus[i].SetData([]chartSeries{
+				series(fmt.Sprintf("GPU %d", index), gpuPath(index, "usage_percent"), primary),
+			}, from, to)
+			w.gpus[i+1].format = formatTemperatureValue(unit)
+			w.gpus[i+1].SetData([]chartSeries{
+				series(fmt.Sprintf("GPU %d", index), gpuPath(index, "temperature"), primary),
+			}, from, to)
+		}
+	})
+}
+
+// maxGPUs bounds the number of GPUs looked up in the samples
+const maxGPUs = 16
+
+// gpuPath returns the metric path of a GPU field, e.g. "gpus[0].usage_percent"
+func gpuPath(index int, field string) string {
+	return "gpus[" + strconv.Itoa(index) + "]." + field
+}
+
+// seriesOf extracts the values of a metric path from samples
+func seriesOf(samples []history.Sample, path string) []history.Point {
+	var points []history.Point
+	for _, sample := range samples {
+		if value, ok := sample.Values[path]; ok {
+			points = append(points, history.Point{Time: sample.Time, Value: value})
+		}
+	}
+	return points
+}
+
+// formatPercentValue formats a chart value in percent
+func formatPercentValue(value float64) string {
+	return fmt.Sprintf("%.1f%%", value)
+}
+
+// formatRateValue formats a chart value in bytes per second
+func formatRateValue(value float64) string {
+	return types.FormatBytes(value) + "/s"
+}
+
+// selectSpan hands a range selection to run without blocking the UI, replacing
+// a selection run hasn't picked up yet
+func (w *historyWindow) selectSpan(span time.Duration) {
+	for {
+		select {
+		case w.spans <- span:
+			return
+		default:
+		}
+
+		select {
+		case <-w.spans:
+		default:
+		}
+	}
+}
+
+// formatTemperatureValue returns a formatter of Celsius chart values in the given unit
+func formatTemperatureValue(unit string) func(float64) string {
+	return func(celsius float64) string {
+		return types.FormatTemperature(celsius, unit)
+	}
+}
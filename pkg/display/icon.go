@@ -0,0 +1,181 @@
+package display
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+	"time"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/types"
+
+	"fyne.io/fyne/v2"
+)
+
+const (
+	// trayIconSize is the width and height of the rendered tray icon, which
+	// the tray scales down to its own size
+	trayIconSize = 64
+
+	// trayIconGap is the space between the bars or bands of the tray icon
+	trayIconGap = 4
+
+	// sparklineColumnWidth is the width of every sample of a sparkline
+	sparklineColumnWidth = 2
+)
+
+var (
+	trayIconTrack    = color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0x50}
+	trayIconOK       = color.NRGBA{R: 0x4c, G: 0xaf, B: 0x50, A: 0xff}
+	trayIconWarning  = color.NRGBA{R: 0xff, G: 0xc1, B: 0x07, A: 0xff}
+	trayIconCritical = color.NRGBA{R: 0xf4, G: 0x43, B: 0x36, A: 0xff}
+)
+
+// updateTrayIcon renders the tray icon from the latest metrics, or restores
+// the static icon, only replacing it when it changed
+func (d *Display) updateTrayIcon(metrics *types.SystemMetrics) {
+	cfg := d.config.Snapshot()
+
+	var img *image.NRGBA
+	switch cfg.TrayIcon {
+	case "sparkline":
+		img = d.renderSparklineIcon(metrics, &cfg)
+	case "bars":
+		img = renderBarsIcon(metrics.Values(), &cfg)
+	}
+
+	// Without anything to draw, show the static icon
+	if img == nil {
+		if d.trayIcon != nil {
+			d.trayIcon = nil
+			d.setSystemTrayIcon()
+		}
+		return
+	}
+
+	var data bytes.Buffer
+	if err := png.Encode(&data, img); err != nil {
+		logs.Error("Failed to encode tray icon: %v", err)
+		return
+	}
+	if bytes.Equal(data.Bytes(), d.trayIcon) {
+		return
+	}
+
+	d.trayIcon = data.Bytes()
+	d.app.SetSystemTrayIcon(fyne.NewStaticResource("p-monitor-tray.png", d.trayIcon))
+}
+
+// renderBarsIcon draws the configured metrics as side by side vertical bars,
+// returning nil when there are none
+func renderBarsIcon(values map[string]float64, cfg *config.Config) *image.NRGBA {
+	n := len(cfg.TrayIconMetrics)
+	if n == 0 {
+		return nil
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, trayIconSize, trayIconSize))
+	width := (trayIconSize - (n-1)*trayIconGap) / n
+	for i, metric := range cfg.TrayIconMetrics {
+		x := i * (width + trayIconGap)
+		fill(img, x, 0, width, trayIconSize, trayIconTrack)
+
+		value, ok := trayIconValue(metric, values)
+		if !ok {
+			continue
+		}
+		height := scaled(value, trayIconSize)
+		fill(img, x, trayIconSize-height, width, height, thresholdColor(value, cfg))
+	}
+	return img
+}
+
+// renderSparklineIcon draws the recent history of every configured metric in
+// a horizontal band, the latest sample on the right, returning nil when there
+// are none
+func (d *Display) renderSparklineIcon(metrics *types.SystemMetrics, cfg *config.Config) *image.NRGBA {
+	n := len(cfg.TrayIconMetrics)
+	if n == 0 {
+		return nil
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, trayIconSize, trayIconSize))
+
+	// Only read back as many samples as there are columns
+	columns := trayIconSize / sparklineColumnWidth
+	interval := time.Duration(cfg.GetUpdateIntervalSeconds()) * time.Second
+	samples := d.monitor.History().Range(metrics.Updated.Add(-time.Duration(columns+1)*interval), time.Time{})
+	if len(samples) > columns {
+		samples = samples[len(samples)-columns:]
+	}
+
+	height := (trayIconSize - (n-1)*trayIconGap) / n
+	for i, metric := range cfg.TrayIconMetrics {
+		y := i * (height + trayIconGap)
+		fill(img, 0, y, trayIconSize, height, trayIconTrack)
+
+		// Right-align the samples, leaving the left empty until the history fills up
+		x := trayIconSize - len(samples)*sparklineColumnWidth
+		for _, sample := range samples {
+			if value, ok := trayIconValue(metric, sample.Values); ok {
+				h := max(scaled(value, height), 1)
+				fill(img, x, y+height-h, sparklineColumnWidth, h, thresholdColor(value, cfg))
+			}
+			x += sparklineColumnWidth
+		}
+	}
+	return img
+}
+
+// trayIconValue returns the usage percentage of a tray icon metric, the
+// busiest GPU for "gpu", reporting false when it wasn't collected
+func trayIconValue(metric string, values map[string]float64) (float64, bool) {
+	switch metric {
+	case "cpu":
+		value, ok := values["cpu.usage_percent"]
+		return value, ok
+	case "memory":
+		value, ok := values["memory.used_percent"]
+		return value, ok
+	case "gpu":
+		busiest, found := 0.0, false
+		for path, value := range values {
+			if strings.HasPrefix(path, "gpus[") && strings.HasSuffix(path, "].usage_percent") {
+				busiest, found = math.Max(busiest, value), true
+			}
+		}
+		return busiest, found
+	}
+	return 0, false
+}
+
+// thresholdColor returns the colour of a usage percentage
+func thresholdColor(value float64, cfg *config.Config) color.NRGBA {
+	switch {
+	case value >= cfg.TrayIconCritical:
+		return trayIconCritical
+	case value >= cfg.TrayIconWarning:
+		return trayIconWarning
+	default:
+		return trayIconOK
+	}
+}
+
+// scaled returns the part of length covered by a percentage
+func scaled(percent float64, length int) int {
+	percent = math.Min(math.Max(percent, 0), 100)
+	return int(math.Round(percent / 100 * float64(length)))
+}
+
+// fill paints a rectangle of an image
+func fill(img *image.NRGBA, x, y, width, height int, c color.NRGBA) {
+	for py := y; py < y+height; py++ {
+		for px := x; px < x+width; px++ {
+			img.SetNRGBA(px, py, c)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"p-monitor/pkg/history"
+)
+
+// Supported export formats
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Formats lists the supported export formats
+var Formats = []string{FormatCSV, FormatJSONL}
+
+// Write writes samples as a flat table with a "time" column followed by one
+// column per metric path, sorted. Only paths matching one of the metrics
+// selectors are written, every path when there are none. Values a sample
+// lacks are left empty in CSV and omitted in JSON Lines.
+func Write(w io.Writer, format string, samples []history.Sample, metrics []string) error {
+	columns := Columns(samples, metrics)
+
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, samples, columns)
+	case FormatJSONL:
+		return writeJSONL(w, samples, columns)
+	default:
+		return fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// Columns returns the sorted metric paths of samples matching the metrics
+// selectors. A selector matches its own path and every path below it, so
+// "cpu" selects "cpu.usage_percent" and "cpu.cores[0].usage_percent".
+func Columns(samples []history.Sample, metrics []string) []string {
+	seen := make(map[string]struct{})
+	for _, sample := range samples {
+		for path := range sample.Values {
+			if _, ok := seen[path]; !ok && matches(path, metrics) {
+				seen[path] = struct{}{}
+			}
+		}
+	}
+
+	columns := make([]string, 0, len(seen))
+	for path := range seen {
+		columns = append(columns, path)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// matches reports whether a metric path is selected by any of the selectors
+func matches(path string, selectors []string) bool {
+	if len(selectors) == 0 {
+		return true
+	}
+	for _, selector := range selectors {
+		if path == selector || strings.HasPrefix(path, selector+".") || strings.HasPrefix(path, selector+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCSV writes samples as CSV with a header row
+func writeCSV(w io.Writer, samples []history.Sample, columns []string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append([]string{"time"}, columns...)); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+
+	row := make([]string, len(columns)+1)
+	for _, sample := range samples {
+		row[0] = sample.Time.Format(time.RFC3339)
+		for i, path := range columns {
+			row[i+1] = ""
+			if value, ok := sample.Values[path]; ok {
+				row[i+1] = strconv.FormatFloat(value, 'f', -1, 64)
+			}
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write row: %v", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write rows: %v", err)
+	}
+	return nil
+}
+
+// writeJSONL writes one JSON object per sample, keyed by "time" and the metric paths
+func writeJSONL(w io.Writer, samples []history.Sample, columns []string) error {
+	writer := bufio.NewWriter(w)
+
+	for _, sample := range samples {
+		// Written by hand to keep "time" first and the paths in column order
+		line := []byte(`{"time":"` + sample.Time.Format(time.RFC3339) + `"`)
+		for _, path := range columns {
+			// Missing values are omitted, and NaN has no JSON representation
+			value, ok := sample.Values[path]
+			if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			key, _ := json.Marshal(path)
+			line = append(line, ',')
+			line = append(line, key...)
+			line = append(line, ':')
+			line = strconv.AppendFloat(line, value, 'f', -1, 64)
+		}
+		line = append(line, '}', '\n')
+
+		if _, err := writer.Write(line); err != nil {
+			return fmt.Errorf("failed to write sample: %v", err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write samples: %v", err)
+	}
+	return nil
+}
+
+// ParseSelectors splits a comma-separated list of metric selectors
+func ParseSelectors(list string) []string {
+	var selectors []string
+	for _, selector := range strings.Split(list, ",") {
+		if selector = strings.TrimSpace(selector); selector != "" {
+			selectors = append(selectors, selector)
+		}
+	}
+	return selectors
+}
@@ -0,0 +1,238 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/types"
+)
+
+const (
+	// contentType is the media type of the OpenMetrics text format
+	contentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+	// shutdownTimeout bounds how long in-flight scrapes may take on shutdown
+	shutdownTimeout = 5 * time.Second
+)
+
+// MetricsSource provides the latest collected metrics
+type MetricsSource interface {
+	GetMetrics() *types.SystemMetrics
+}
+
+// Exporter serves the latest metrics in the OpenMetrics text format
+type Exporter struct {
+	source MetricsSource
+}
+
+// New creates a new exporter reading from source
+func New(source MetricsSource) *Exporter {
+	return &Exporter{source: source}
+}
+
+// Serve serves /metrics on addr until ctx is cancelled
+func (e *Exporter) Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logs.Info("Serving OpenMetrics on http://%s/metrics", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP writes the latest metrics
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	reg := newRegistry()
+	if metrics := e.source.GetMetrics(); metrics != nil {
+		addMetrics(reg, metrics)
+	}
+	reg.gauge("pmonitor_scrape_duration_seconds", "seconds", "Time spent rendering this scrape.",
+		time.Since(start).Seconds())
+
+	w.Header().Set("Content-Type", contentType)
+	if err := reg.write(w); err != nil {
+		logs.Error("Failed to write metrics response: %v", err)
+	}
+}
+
+// addMetrics maps every SystemMetrics field to metric families
+func addMetrics(reg *registry, metrics *types.SystemMetrics) {
+	reg.gauge("pmonitor_last_update_timestamp_seconds", "seconds", "Time of the last collection.",
+		float64(metrics.Updated.UnixNano())/1e9)
+
+	addDiskMetrics(reg, metrics)
+	addMemoryMetrics(reg, metrics)
+	addCPUMetrics(reg, metrics)
+	addGPUMetrics(reg, metrics)
+	addNetworkMetrics(reg, metrics)
+	addServiceMetrics(reg, metrics)
+	addContainerMetrics(reg, metrics)
+	addCollectorMetrics(reg, metrics)
+}
+
+// addDiskMetrics maps the usage of "/" and the other writable filesystems,
+// and drive health
+func addDiskMetrics(reg *registry, metrics *types.SystemMetrics) {
+	disks := metrics.Mounts
+	if metrics.Disk != nil {
+		disks = append([]*types.DiskMetrics{metrics.Disk}, disks...)
+	}
+	for _, disk := range disks {
+		if disk.Error != "" {
+			continue
+		}
+		mount := label{"mount", disk.Mount}
+		reg.gauge("pmonitor_disk_total_bytes", "bytes", "Total filesystem capacity.", float64(disk.Total), mount)
+		reg.gauge("pmonitor_disk_used_bytes", "bytes", "Used filesystem space.", float64(disk.Used), mount)
+		reg.gauge("pmonitor_disk_used_percent", "", "Used filesystem space in percent.", disk.UsedPercent, mount)
+	}
+
+	for _, drive := range metrics.Drives {
+		labels := []label{{"device", drive.Device}, {"model", drive.Model}, {"type", drive.Type}}
+		if drive.Temperature > 0 {
+			reg.gauge("pmonitor_drive_temperature_celsius", "celsius", "Drive temperature.", drive.Temperature, labels...)
+		}
+		if drive.Error != "" {
+			continue
+		}
+		reg.gauge("pmonitor_drive_percentage_used", "", "NVMe endurance used in percent.", drive.PercentageUsed, labels...)
+		reg.gauge("pmonitor_drive_available_spare_percent", "", "NVMe available spare in percent.", drive.AvailableSpare, labels...)
+		reg.gauge("pmonitor_drive_media_errors", "", "Media errors or uncorrectable sectors reported by the drive.", float64(drive.MediaErrors), labels...)
+		if drive.Passed != nil {
+			reg.gauge("pmonitor_drive_smart_passed", "", "Whether the SMART self-assessment passed (1) or failed (0).", boolValue(*drive.Passed), labels...)
+		}
+		reg.gauge("pmonitor_drive_warnings", "", "Number of health warnings raised for the drive.", float64(len(drive.Warnings)), labels...)
+	}
+}
+
+// addMemoryMetrics maps memory usage
+func addMemoryMetrics(reg *registry, metrics *types.SystemMetrics) {
+	memory := metrics.Memory
+	if memory == nil || memory.Error != "" {
+		return
+	}
+
+	reg.gauge("pmonitor_memory_total_bytes", "bytes", "Total memory.", float64(memory.Total))
+	reg.gauge("pmonitor_memory_used_bytes", "bytes", "Used memory.", float64(memory.Used))
+	reg.gauge("pmonitor_memory_used_percent", "", "Used memory in percent.", memory.UsedPercent)
+}
+
+// addCPUMetrics maps CPU usage, per core usage and temperature
+func addCPUMetrics(reg *registry, metrics *types.SystemMetrics) {
+	cpu := metrics.CPU
+	if cpu == nil || cpu.Error != "" {
+		return
+	}
+
+	reg.gauge("pmonitor_cpu_usage_percent", "", "Total CPU usage in percent.", cpu.UsagePercent)
+	for core, usage := range cpu.CoreUsagePercent {
+		reg.gauge("pmonitor_cpu_core_usage_percent", "", "CPU usage of a single core in percent.", usage,
+			label{"core", strconv.Itoa(core)})
+	}
+	if cpu.Temperature > 0 {
+		reg.gauge("pmonitor_cpu_temperature_celsius", "celsius", "CPU temperature.", cpu.Temperature)
+	}
+}
+
+// addGPUMetrics maps the usage and temperature of every GPU
+func addGPUMetrics(reg *registry, metrics *types.SystemMetrics) {
+	for i, gpu := range metrics.GPUs {
+		labels := []label{{"gpu", strconv.Itoa(i)}, {"name", gpu.Name}, {"type", gpu.Type}}
+
+		reg.gauge("pmonitor_gpu_up", "", "Whether the GPU could be monitored (1) or not (0).", boolValue(gpu.Error == ""), labels...)
+		if gpu.Error != "" {
+			continue
+		}
+		reg.gauge("pmonitor_gpu_usage_percent", "", "GPU usage in percent.", gpu.UsagePercent, labels...)
+		if gpu.Temperature > 0 {
+			reg.gauge("pmonitor_gpu_temperature_celsius", "celsius", "GPU temperature.", gpu.Temperature, labels...)
+		}
+	}
+}
+
+// addNetworkMetrics maps the traffic of all network interfaces
+func addNetworkMetrics(reg *registry, metrics *types.SystemMetrics) {
+	network := metrics.Network
+	if network == nil || network.Error != "" {
+		return
+	}
+
+	reg.counter("pmonitor_network_receive_bytes", "bytes", "Bytes received by all interfaces except loopback.", float64(network.RxBytes))
+	reg.counter("pmonitor_network_transmit_bytes", "bytes", "Bytes transmitted by all interfaces except loopback.", float64(network.TxBytes))
+	reg.gauge("pmonitor_network_receive_bytes_per_second", "bytes_per_second", "Bytes received per second.", network.RxRate)
+	reg.gauge("pmonitor_network_transmit_bytes_per_second", "bytes_per_second", "Bytes transmitted per second.", network.TxRate)
+}
+
+// addServiceMetrics maps the usage of every systemd service, and of every
+// top-level slice under families of its own, as it includes its services
+func addServiceMetrics(reg *registry, metrics *types.SystemMetrics) {
+	for _, service := range metrics.Services {
+		labels := []label{{"service", service.Name}, {"path", service.Path}}
+
+		reg.gauge("pmonitor_service_cpu_percent", "", "CPU usage of the cgroup in percent of total capacity.", service.CPUPercent, labels...)
+		reg.gauge("pmonitor_service_memory_bytes", "bytes", "Memory charged to the cgroup.", float64(service.MemoryBytes), labels...)
+		reg.counter("pmonitor_service_io_read_bytes", "bytes", "Bytes read by the cgroup.", float64(service.IOReadBytes), labels...)
+		reg.counter("pmonitor_service_io_write_bytes", "bytes", "Bytes written by the cgroup.", float64(service.IOWriteBytes), labels...)
+		reg.gauge("pmonitor_service_io_read_bytes_per_second", "bytes_per_second", "Bytes read by the cgroup per second.", service.IOReadRate, labels...)
+		reg.gauge("pmonitor_service_io_write_bytes_per_second", "bytes_per_second", "Bytes written by the cgroup per second.", service.IOWriteRate, labels...)
+	}
+
+	for _, slice := range metrics.Slices {
+		labels := []label{{"slice", slice.Name}}
+
+		reg.gauge("pmonitor_slice_cpu_percent", "", "CPU usage of the slice, including its services, in percent of total capacity.", slice.CPUPercent, labels...)
+		reg.gauge("pmonitor_slice_memory_bytes", "bytes", "Memory charged to the slice, including its services.", float64(slice.MemoryBytes), labels...)
+		reg.counter("pmonitor_slice_io_read_bytes", "bytes", "Bytes read by the slice, including its services.", float64(slice.IOReadBytes), labels...)
+		reg.counter("pmonitor_slice_io_write_bytes", "bytes", "Bytes written by the slice, including its services.", float64(slice.IOWriteBytes), labels...)
+		reg.gauge("pmonitor_slice_io_read_bytes_per_second", "bytes_per_second", "Bytes read by the slice, including its services, per second.", slice.IOReadRate, labels...)
+		reg.gauge("pmonitor_slice_io_write_bytes_per_second", "bytes_per_second", "Bytes written by the slice, including its services, per second.", slice.IOWriteRate, labels...)
+	}
+}
+
+// addContainerMetrics maps the usage of every container
+func addContainerMetrics(reg *registry, metrics *types.SystemMetrics) {
+	for _, container := range metrics.Containers {
+		labels := []label{{"container", container.Name}, {"id", container.ID}, {"runtime", container.Runtime}}
+
+		reg.gauge("pmonitor_container_cpu_percent", "", "CPU usage of the container in percent of total capacity.", container.CPUPercent, labels...)
+		reg.gauge("pmonitor_container_memory_bytes", "bytes", "Memory charged to the container.", float64(container.MemoryBytes), labels...)
+		reg.counter("pmonitor_container_network_receive_bytes", "bytes", "Bytes received by the container.", float64(container.NetRxBytes), labels...)
+		reg.counter("pmonitor_container_network_transmit_bytes", "bytes", "Bytes transmitted by the container.", float64(container.NetTxBytes), labels...)
+		reg.gauge("pmonitor_container_network_receive_bytes_per_second", "bytes_per_second", "Bytes received by the container per second.", container.NetRxRate, labels...)
+		reg.gauge("pmonitor_container_network_transmit_bytes_per_second", "bytes_per_second", "Bytes transmitted by the container per second.", container.NetTxRate, labels...)
+	}
+}
+
+// addCollectorMetrics maps the duration and outcome of every collector
+func addCollectorMetrics(reg *registry, metrics *types.SystemMetrics) {
+	for _, collector := range metrics.Collectors {
+		name := label{"collector", collector.Name}
+		reg.gauge("pmonitor_collector_duration_seconds", "seconds", "Time spent by the collector in the last collection.", collector.Duration, name)
+		reg.gauge("pmonitor_collector_error", "", "Whether the collector failed (1) or not (0) in the last collection.", boolValue(collector.Error != ""), name)
+	}
+}
+
+// boolValue converts a boolean to a 1 or 0 sample value
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,133 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Metric types of the OpenMetrics text format
+const (
+	gaugeType   = "gauge"
+	counterType = "counter"
+)
+
+// label is a single name="value" pair of a sample
+type label struct {
+	name  string
+	value string
+}
+
+// sample is a single value of a metric family
+type sample struct {
+	labels []label
+	value  float64
+}
+
+// family groups the samples of a metric, which OpenMetrics requires to be
+// written together under a single TYPE, UNIT and HELP header
+type family struct {
+	name       string
+	metricType string
+	unit       string
+	help       string
+	samples    []sample
+}
+
+// registry collects metric families in the order they are first declared
+type registry struct {
+	families []*family
+	byName   map[string]*family
+}
+
+// newRegistry creates an empty registry
+func newRegistry() *registry {
+	return &registry{byName: make(map[string]*family)}
+}
+
+// gauge records a gauge sample
+func (r *registry) gauge(name, unit, help string, value float64, labels ...label) {
+	r.add(name, gaugeType, unit, help, value, labels)
+}
+
+// counter records a counter sample
+func (r *registry) counter(name, unit, help string, value float64, labels ...label) {
+	r.add(name, counterType, unit, help, value, labels)
+}
+
+// add records a sample, declaring its family on first use
+func (r *registry) add(name, metricType, unit, help string, value float64, labels []label) {
+	f, ok := r.byName[name]
+	if !ok {
+		f = &family{name: name, metricType: metricType, unit: unit, help: help}
+		r.byName[name] = f
+		r.families = append(r.families, f)
+	}
+
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+// write writes every family in the OpenMetrics text format
+func (r *registry) write(w io.Writer) error {
+	var b strings.Builder
+
+	for _, f := range r.families {
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.name, f.metricType)
+		if f.unit != "" {
+			fmt.Fprintf(&b, "# UNIT %s %s\n", f.name, f.unit)
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n", f.name, escape(f.help))
+
+		// Counter samples carry the _total suffix, their family name doesn't
+		sampleName := f.name
+		if f.metricType == counterType {
+			sampleName += "_total"
+		}
+
+		for _, s := range f.samples {
+			b.WriteString(sampleName)
+			writeLabels(&b, s.labels)
+			b.WriteByte(' ')
+			b.WriteString(formatValue(s.value))
+			b.WriteByte('\n')
+		}
+	}
+
+	b.WriteString("# EOF\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeLabels writes a {name="value",...} label set, if any
+func writeLabels(b *strings.Builder, labels []label) {
+	if len(labels) == 0 {
+		return
+	}
+
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.name)
+		b.WriteString(`="`)
+		b.WriteString(escape(l.value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+}
+
+// formatValue formats a sample value
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// escaper escapes backslashes, quotes and line feeds in label values and HELP text
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape escapes a label value or HELP text
+func escape(text string) string {
+	return escaper.Replace(text)
+}
@@ -0,0 +1,282 @@
+package forecast
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/history"
+	"p-monitor/pkg/monitor"
+)
+
+const (
+	// minPoints is the number of samples needed before forecasting
+	minPoints = 10
+
+	// minSpan is the time the samples must cover before forecasting, so
+	// that a short burst isn't extrapolated
+	minSpan = 2 * time.Minute
+
+	// maxFullIn is the furthest forecast made, beyond which usage is
+	// considered flat. It also keeps the estimate within a time.Duration.
+	maxFullIn = 10 * 365 * 24 * time.Hour
+)
+
+// Resources forecast, identifying a Forecast. Other filesystems than the
+// root one are identified by DiskResource.
+const (
+	ResourceDisk   = "disk"
+	ResourceMemory = "memory"
+)
+
+// DiskResource returns the resource of the filesystem mounted at mount
+func DiskResource(mount string) string {
+	if mount == "/" {
+		return ResourceDisk
+	}
+	return ResourceDisk + ":" + mount
+}
+
+// Forecast estimates when a resource runs full from the trend of its usage
+type Forecast struct {
+	Resource string        `json:"resource"`
+	Name     string        `json:"name"`  // Display name, e.g. "Disk /" or "RAM"
+	Used     float64       `json:"used"`  // Bytes
+	Total    float64       `json:"total"` // Bytes
+	Rate     float64       `json:"rate"`  // Bytes per second the usage grows by
+	FullIn   time.Duration `json:"full_in"`
+}
+
+// Event reports a forecast getting under the configured horizon
+type Event struct {
+	Forecast Forecast      `json:"forecast"`
+	Horizon  time.Duration `json:"horizon"`
+}
+
+// Forecaster fits a linear regression to the recent disk and memory usage of
+// every collection. It is safe for concurrent use.
+type Forecaster struct {
+	monitor *monitor.Monitor
+	config  *config.Config
+
+	mu          sync.RWMutex
+	latest      map[string]Forecast // Growing resources, by resource
+	below       map[string]bool     // Resources whose forecast is under the horizon
+	subscribers map[chan Event]struct{}
+	shortWindow bool // Whether the history was reported shorter than the window, only used by Run
+}
+
+// New creates a forecaster reading the window and horizon from cfg
+func New(m *monitor.Monitor, cfg *config.Config) *Forecaster {
+	return &Forecaster{
+		monitor:     m,
+		config:      cfg,
+		latest:      make(map[string]Forecast),
+		below:       make(map[string]bool),
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Run updates the forecasts after every collection until ctx is cancelled
+func (f *Forecaster) Run(ctx context.Context) {
+	updates := f.monitor.Subscribe()
+	defer f.monitor.Unsubscribe(updates)
+
+	for {
+		select {
+		case metrics, ok := <-updates:
+			if !ok {
+				return
+			}
+			f.update(metrics)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// update recomputes the forecasts, notifying the resources that got under the horizon
+func (f *Forecaster) update(metrics *monitor.SystemMetrics) {
+	cfg := f.config.Snapshot()
+	window, _ := cfg.ForecastWindowDuration()
+	horizon, _ := cfg.ForecastHorizonDuration()
+
+	now := metrics.Updated
+	from := now.Add(-window)
+	h := f.monitor.History()
+	f.checkWindow(cfg, window)
+
+	forecasts := make(map[string]Forecast)
+	disks := metrics.Mounts
+	if metrics.Disk != nil {
+		disks = append([]*monitor.DiskMetrics{metrics.Disk}, disks...)
+	}
+	for _, disk := range disks {
+		if disk.Error != "" {
+			continue
+		}
+		path := "disk.used"
+		if disk.Mount != "/" {
+			path = fmt.Sprintf("mounts[%s].used", disk.Mount)
+		}
+		if forecast, ok := predict(h.Series(path, from, now), float64(disk.Total), now); ok {
+			forecast.Resource = DiskResource(disk.Mount)
+			forecast.Name = "Disk " + disk.Mount
+			forecasts[forecast.Resource] = forecast
+		}
+	}
+	if memory := metrics.Memory; memory != nil && memory.Error == "" {
+		if forecast, ok := predict(h.Series("memory.used", from, now), float64(memory.Total), now); ok {
+			forecast.Resource = ResourceMemory
+			forecast.Name = "RAM"
+			forecasts[ResourceMemory] = forecast
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.latest = forecasts
+	below := make(map[string]bool)
+	for resource, forecast := range forecasts {
+		if horizon <= 0 || forecast.FullIn > horizon {
+			continue
+		}
+		below[resource] = true
+		if !f.below[resource] {
+			logs.Info("%s is forecast to be full in %s", forecast.Name, FormatDuration(forecast.FullIn))
+			f.notify(Event{Forecast: forecast, Horizon: horizon})
+		}
+	}
+	f.below = below
+}
+
+// checkWindow logs once when the in-memory history holds less than the
+// forecast window, which forecasts are then fitted to instead
+func (f *Forecaster) checkWindow(cfg config.Config, window time.Duration) {
+	held := time.Duration(cfg.HistorySize) * time.Duration(cfg.GetUpdateIntervalSeconds()) * time.Second
+	short := held < window
+	if short && !f.shortWindow {
+		logs.Error("forecast_window of %v is longer than the %v held in memory by history_size, forecasts are fitted to the last %v", window, held, held)
+	}
+	f.shortWindow = short
+}
+
+// Get returns the forecast of a resource, reporting false when its usage
+// isn't growing or there are too few samples
+func (f *Forecaster) Get(resource string) (Forecast, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	forecast, ok := f.latest[resource]
+	return forecast, ok
+}
+
+// Within returns the forecast of a resource when it is under the configured horizon
+func (f *Forecaster) Within(resource string) (Forecast, bool) {
+	forecast, ok := f.Get(resource)
+	if !ok {
+		return forecast, false
+	}
+	cfg := f.config.Snapshot()
+	horizon, _ := cfg.ForecastHorizonDuration()
+	return forecast, horizon > 0 && forecast.FullIn <= horizon
+}
+
+// Subscribe returns a channel receiving the forecasts getting under the
+// horizon. Subscribers must call Unsubscribe when done.
+func (f *Forecaster) Subscribe() <-chan Event {
+	ch := make(chan Event, 4)
+
+	f.mu.Lock()
+	f.subscribers[ch] = struct{}{}
+	f.mu.Unlock()
+
+	return ch
+}
+
+// Unsubscribe stops delivering events to a channel returned by Subscribe
+func (f *Forecaster) Unsubscribe(sub <-chan Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for ch := range f.subscribers {
+		if ch == sub {
+			delete(f.subscribers, ch)
+			close(ch)
+			return
+		}
+	}
+}
+
+// notify sends an event to every subscriber. f.mu must be held.
+func (f *Forecaster) notify(event Event) {
+	for ch := range f.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// predict fits a least-squares line to the used bytes and extrapolates when
+// they reach total, reporting false when usage isn't growing or won't reach
+// total within maxFullIn
+func predict(points []history.Point, total float64, now time.Time) (Forecast, bool) {
+	if len(points) < minPoints || points[len(points)-1].Time.Sub(points[0].Time) < minSpan {
+		return Forecast{}, false
+	}
+
+	// Seconds since the first point keep the sums well conditioned
+	origin := points[0].Time
+	var sumX, sumY float64
+	for _, point := range points {
+		sumX += point.Time.Sub(origin).Seconds()
+		sumY += point.Value
+	}
+	n := float64(len(points))
+	meanX, meanY := sumX/n, sumY/n
+
+	var covariance, variance float64
+	for _, point := range points {
+		dx := point.Time.Sub(origin).Seconds() - meanX
+		covariance += dx * (point.Value - meanY)
+		variance += dx * dx
+	}
+	if variance == 0 {
+		return Forecast{}, false
+	}
+
+	slope := covariance / variance
+	if slope <= 0 {
+		return Forecast{}, false
+	}
+
+	used := meanY + slope*(now.Sub(origin).Seconds()-meanX)
+	remaining := max((total-used)/slope, 0)
+	if remaining > maxFullIn.Seconds() {
+		return Forecast{}, false
+	}
+
+	return Forecast{
+		Used:   points[len(points)-1].Value,
+		Total:  total,
+		Rate:   slope,
+		FullIn: time.Duration(remaining * float64(time.Second)),
+	}, true
+}
+
+// FormatDuration formats a forecast roughly, e.g. "~45m", "~3h" or "~2d"
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("~%dm", max(int(d.Round(time.Minute).Minutes()), 1))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("~%dh", int(d.Round(time.Hour).Hours()))
+	default:
+		return fmt.Sprintf("~%dd", int(d.Round(24*time.Hour).Hours()/24))
+	}
+}
@@ -0,0 +1,40 @@
+package gpu
+
+import (
+	"os/exec"
+	"strings"
+
+	"p-monitor/pkg/command"
+)
+
+// FakeRunner replays captured command results instead of running commands.
+// Results are keyed by the full command line, e.g. "radeontop -l 1 -d -".
+type FakeRunner struct {
+	Results map[string]*command.Result
+}
+
+// NewFakeRunner creates a runner replaying the given results
+func NewFakeRunner(results map[string]*command.Result) *FakeRunner {
+	return &FakeRunner{Results: results}
+}
+
+// LookPath reports a command as installed when any result was captured for it
+func (f *FakeRunner) LookPath(name string) (string, error) {
+	for commandLine := range f.Results {
+		if commandLine == name || strings.HasPrefix(commandLine, name+" ") {
+			return name, nil
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// Run returns the result captured for the command line
+func (f *FakeRunner) Run(name string, args ...string) (*command.Result, error) {
+	commandLine := strings.Join(append([]string{name}, args...), " ")
+
+	result, ok := f.Results[commandLine]
+	if !ok {
+		return nil, &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+	return result, nil
+}
@@ -1,30 +1,49 @@
 package gpu
 
 import (
+	"errors"
 	"fmt"
-	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
 
 	"p-monitor/internal/logs"
+	"p-monitor/pkg/command"
 	"p-monitor/pkg/types"
 )
 
-// CollectAllGPUMetrics collects metrics from all available GPUs
+// errNotAvailable is returned for nvidia-smi fields the GPU doesn't report
+var errNotAvailable = errors.New("not reported by the GPU")
+
+// Collector collects GPU metrics through the vendor command-line tools
+type Collector struct {
+	runner command.Runner
+}
+
+// NewCollector creates a GPU collector running tools through runner
+func NewCollector(runner command.Runner) *Collector {
+	return &Collector{runner: runner}
+}
+
+// CollectAllGPUMetrics collects metrics from all available GPUs on the local machine
 func CollectAllGPUMetrics() []*types.GPUMetrics {
+	return NewCollector(command.ExecRunner{}).CollectAll()
+}
+
+// CollectAll collects metrics from all available GPUs
+func (c *Collector) CollectAll() []*types.GPUMetrics {
 	var gpus []*types.GPUMetrics
 
 	// Collect NVIDIA GPUs
-	nvidiaGPUs := collectNVIDIAGPUs()
+	nvidiaGPUs := c.collectNVIDIAGPUs()
 	gpus = append(gpus, nvidiaGPUs...)
 
 	// Collect AMD GPUs
-	amdGPUs := collectAMDGPUs()
+	amdGPUs := c.collectAMDGPUs()
 	gpus = append(gpus, amdGPUs...)
 
 	// Collect integrated GPU (if available)
-	integratedGPU := collectIntegratedGPU()
+	integratedGPU := c.collectIntegratedGPU()
 	if integratedGPU != nil {
 		gpus = append(gpus, integratedGPU)
 	}
@@ -33,24 +52,28 @@ func CollectAllGPUMetrics() []*types.GPUMetrics {
 }
 
 // collectNVIDIAGPUs collects metrics from NVIDIA GPUs using nvidia-smi
-func collectNVIDIAGPUs() []*types.GPUMetrics {
+func (c *Collector) collectNVIDIAGPUs() []*types.GPUMetrics {
 	var gpus []*types.GPUMetrics
 
 	// Check if nvidia-smi is available
-	if !commandExists("nvidia-smi") {
+	if !c.commandExists("nvidia-smi") {
 		logs.Debug("nvidia-smi not found, skipping NVIDIA GPU monitoring")
 		return gpus
 	}
 
 	// Run nvidia-smi to get GPU information
-	cmd := exec.Command("nvidia-smi", "--query-gpu=index,name,utilization.gpu,temperature.gpu", "--format=csv,noheader,nounits")
-	output, err := cmd.Output()
+	output, err := c.output("nvidia-smi", "--query-gpu=index,name,utilization.gpu,temperature.gpu", "--format=csv,noheader,nounits")
 	if err != nil {
+		// Report the failure, e.g. a driver mismatch, instead of hiding the GPUs
 		logs.Error("Failed to run nvidia-smi: %v", err)
-		return gpus
+		return append(gpus, &types.GPUMetrics{
+			Name:  "NVIDIA GPU",
+			Type:  "nvidia",
+			Error: fmt.Sprintf("failed to run nvidia-smi: %v", err),
+		})
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	lines := strings.Split(strings.TrimSpace(output), "\n")
 	for _, line := range lines {
 		if strings.TrimSpace(line) == "" {
 			continue
@@ -66,24 +89,27 @@ func collectNVIDIAGPUs() []*types.GPUMetrics {
 }
 
 // collectAMDGPUs collects metrics from AMD GPUs using radeontop
-func collectAMDGPUs() []*types.GPUMetrics {
+func (c *Collector) collectAMDGPUs() []*types.GPUMetrics {
 	var gpus []*types.GPUMetrics
 
 	// Check if radeontop is available
-	if !commandExists("radeontop") {
+	if !c.commandExists("radeontop") {
 		logs.Debug("radeontop not found, skipping AMD GPU monitoring")
 		return gpus
 	}
 
 	// Run radeontop to get GPU information
-	cmd := exec.Command("radeontop", "-l", "1", "-d", "-")
-	output, err := cmd.Output()
+	output, err := c.output("radeontop", "-l", "1", "-d", "-")
 	if err != nil {
 		logs.Error("Failed to run radeontop: %v", err)
-		return gpus
+		return append(gpus, &types.GPUMetrics{
+			Name:  "AMD GPU",
+			Type:  "amd",
+			Error: fmt.Sprintf("failed to run radeontop: %v", err),
+		})
 	}
 
-	gpu := parseRadeontopOutput(string(output))
+	gpu := parseRadeontopOutput(output)
 	if gpu != nil {
 		gpus = append(gpus, gpu)
 	}
@@ -92,23 +118,22 @@ func collectAMDGPUs() []*types.GPUMetrics {
 }
 
 // collectIntegratedGPU collects metrics from integrated GPU
-func collectIntegratedGPU() *types.GPUMetrics {
+func (c *Collector) collectIntegratedGPU() *types.GPUMetrics {
 	// Try to get integrated GPU info from various sources
 	// This is a simplified implementation - in practice, you might need to
 	// check different sources depending on the system
 
 	// For now, we'll try to get basic info from lspci or similar
-	if commandExists("lspci") {
-		cmd := exec.Command("lspci", "-v")
-		output, err := cmd.Output()
+	if c.commandExists("lspci") {
+		output, err := c.output("lspci", "-v")
 		if err != nil {
 			logs.Debug("Failed to run lspci: %v", err)
 			return nil
 		}
 
 		// Look for integrated graphics
-		if strings.Contains(string(output), "VGA") &&
-			(strings.Contains(string(output), "Intel") || strings.Contains(string(output), "AMD")) {
+		if strings.Contains(output, "VGA") &&
+			(strings.Contains(output, "Intel") || strings.Contains(output, "AMD")) {
 			return &types.GPUMetrics{
 				Name:         "Integrated GPU",
 				Type:         "integrated",
@@ -132,27 +157,38 @@ func parseNVIDIALine(line string) *types.GPUMetrics {
 
 	index := strings.TrimSpace(parts[0])
 	name := strings.TrimSpace(parts[1])
+	gpu := &types.GPUMetrics{
+		Name: fmt.Sprintf("NVIDIA GPU %s (%s)", index, name),
+		Type: "nvidia",
+	}
 
+	// Without usage the GPU is reported as failed rather than idle
 	usageStr := strings.TrimSpace(parts[2])
-	usage, err := strconv.ParseFloat(usageStr, 64)
+	usage, err := parseNVIDIAValue(usageStr)
 	if err != nil {
-		logs.Error("Failed to parse GPU usage: %s", usageStr)
-		usage = 0
+		gpu.Error = fmt.Sprintf("failed to read usage: %v", err)
 	}
+	gpu.UsagePercent = usage
 
+	// A missing temperature is left at 0, which is read as unavailable
 	tempStr := strings.TrimSpace(parts[3])
-	temperature, err := strconv.ParseFloat(tempStr, 64)
-	if err != nil {
+	temperature, err := parseNVIDIAValue(tempStr)
+	if err != nil && !errors.Is(err, errNotAvailable) {
 		logs.Error("Failed to parse GPU temperature: %s", tempStr)
-		temperature = 0
 	}
+	gpu.Temperature = temperature
 
-	return &types.GPUMetrics{
-		Name:         fmt.Sprintf("NVIDIA GPU %s (%s)", index, name),
-		Type:         "nvidia",
-		UsagePercent: usage,
-		Temperature:  temperature,
+	return gpu
+}
+
+// parseNVIDIAValue parses a numeric nvidia-smi field, returning
+// errNotAvailable for fields the GPU doesn't report, such as "[N/A]" or
+// "[Not Supported]"
+func parseNVIDIAValue(value string) (float64, error) {
+	if strings.HasPrefix(value, "[") || value == "N/A" {
+		return 0, errNotAvailable
 	}
+	return strconv.ParseFloat(value, 64)
 }
 
 // parseRadeontopOutput parses radeontop output
@@ -186,7 +222,19 @@ func parseRadeontopOutput(output string) *types.GPUMetrics {
 }
 
 // commandExists checks if a command exists in the system
-func commandExists(cmd string) bool {
-	_, err := exec.LookPath(cmd)
+func (c *Collector) commandExists(cmd string) bool {
+	_, err := c.runner.LookPath(cmd)
 	return err == nil
 }
+
+// output runs a command and returns its stdout, failing on a non zero exit code
+func (c *Collector) output(name string, args ...string) (string, error) {
+	result, err := c.runner.Run(name, args...)
+	if err != nil {
+		return "", err
+	}
+	if err := result.Err(); err != nil {
+		return "", err
+	}
+	return result.Stdout, nil
+}
@@ -0,0 +1,95 @@
+package gpu
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"p-monitor/pkg/command"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestCollectAll(t *testing.T) {
+	// Every case replays the command results recorded in testdata/<name>.json
+	// and compares the collected GPUs with testdata/<name>.golden.json
+	tests := []string{
+		"none",
+		"nvidia_multi",
+		"nvidia_unavailable",
+		"nvidia_driver_error",
+		"radeontop",
+		"radeontop_error",
+		"mixed",
+	}
+
+	for _, name := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+			if err != nil {
+				t.Fatalf("failed to read recorded results: %v", err)
+			}
+			var results map[string]*command.Result
+			if err := json.Unmarshal(data, &results); err != nil {
+				t.Fatalf("failed to parse recorded results: %v", err)
+			}
+
+			gpus := NewCollector(NewFakeRunner(results)).CollectAll()
+			got, err := json.MarshalIndent(gpus, "", "  ")
+			if err != nil {
+				t.Fatalf("failed to encode GPUs: %v", err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", name+".golden.json")
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatalf("failed to update golden file: %v", err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read golden file: %v", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("collected GPUs differ from %s\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+func TestParseNVIDIAValue(t *testing.T) {
+	tests := []struct {
+		value       string
+		want        float64
+		unavailable bool
+		wantErr     bool
+	}{
+		{value: "13", want: 13},
+		{value: "71.5", want: 71.5},
+		{value: "0", want: 0},
+		{value: "[N/A]", unavailable: true, wantErr: true},
+		{value: "N/A", unavailable: true, wantErr: true},
+		{value: "[Not Supported]", unavailable: true, wantErr: true},
+		{value: "[Unknown Error]", unavailable: true, wantErr: true},
+		{value: "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseNVIDIAValue(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNVIDIAValue(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if errors.Is(err, errNotAvailable) != tt.unavailable {
+				t.Errorf("parseNVIDIAValue(%q) unavailable = %v, want %v", tt.value, !tt.unavailable, tt.unavailable)
+			}
+			if got != tt.want {
+				t.Errorf("parseNVIDIAValue(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
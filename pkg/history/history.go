@@ -0,0 +1,205 @@
+package history
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"p-monitor/pkg/types"
+)
+
+// Sample holds the flattened values of a single collection
+type Sample struct {
+	Time   time.Time          `json:"time"`
+	Values map[string]float64 `json:"values"` // Keyed by metric path, see types.SystemMetrics.Values
+}
+
+// Point is a single value of a metric path
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// Stats aggregates the values of a metric path over a time range
+type Stats struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	Count int     `json:"count"`
+}
+
+// History is a fixed-capacity ring of samples, dropping the oldest sample
+// once full. It is safe for concurrent use.
+type History struct {
+	mu      sync.RWMutex
+	samples []Sample
+	start   int // Index of the oldest sample
+	count   int
+}
+
+// New creates an empty history holding up to capacity samples
+func New(capacity int) *History {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &History{samples: make([]Sample, capacity)}
+}
+
+// Add records a collection
+func (h *History) Add(metrics *types.SystemMetrics) {
+	h.AddSample(Sample{Time: metrics.Updated, Values: metrics.Values()})
+}
+
+// AddSample records a sample, which must not be older than the latest one
+func (h *History) AddSample(sample Sample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	capacity := len(h.samples)
+	if h.count < capacity {
+		h.samples[(h.start+h.count)%capacity] = sample
+		h.count++
+		return
+	}
+
+	// Full: overwrite the oldest sample
+	h.samples[h.start] = sample
+	h.start = (h.start + 1) % capacity
+}
+
+// Resize changes the number of samples held, dropping the oldest ones that
+// don't fit anymore
+func (h *History) Resize(capacity int) {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if capacity == len(h.samples) {
+		return
+	}
+
+	samples := make([]Sample, capacity)
+	count := min(h.count, capacity)
+	for i := 0; i < count; i++ {
+		samples[i] = h.at(h.count - count + i)
+	}
+	h.samples = samples
+	h.start = 0
+	h.count = count
+}
+
+// Len returns the number of samples held
+func (h *History) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.count
+}
+
+// Oldest returns the time of the oldest sample held, or zero when empty
+func (h *History) Oldest() time.Time {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.count == 0 {
+		return time.Time{}
+	}
+	return h.at(0).Time
+}
+
+// Range returns the samples taken within [from, to], oldest first. A zero
+// from or to leaves that end of the range open.
+func (h *History) Range(from, to time.Time) []Sample {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	first, last := h.bounds(from, to)
+	samples := make([]Sample, 0, last-first)
+	for i := first; i < last; i++ {
+		samples = append(samples, h.at(i))
+	}
+	return samples
+}
+
+// Series returns the values of a metric path within [from, to], oldest first.
+// Samples missing the path, for example while its collector failed, are skipped.
+func (h *History) Series(path string, from, to time.Time) []Point {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	first, last := h.bounds(from, to)
+	var points []Point
+	for i := first; i < last; i++ {
+		sample := h.at(i)
+		if value, ok := sample.Values[path]; ok {
+			points = append(points, Point{Time: sample.Time, Value: value})
+		}
+	}
+	return points
+}
+
+// Aggregate returns the minimum, maximum and average of a metric path within
+// [from, to], reporting false when there are no values
+func (h *History) Aggregate(path string, from, to time.Time) (Stats, bool) {
+	points := h.Series(path, from, to)
+	if len(points) == 0 {
+		return Stats{}, false
+	}
+
+	stats := Stats{Min: points[0].Value, Max: points[0].Value, Count: len(points)}
+	sum := 0.0
+	for _, point := range points {
+		stats.Min = min(stats.Min, point.Value)
+		stats.Max = max(stats.Max, point.Value)
+		sum += point.Value
+	}
+	stats.Avg = sum / float64(len(points))
+
+	return stats, true
+}
+
+// Paths returns every metric path present in the history, sorted
+func (h *History) Paths() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	seen := make(map[string]struct{})
+	for i := 0; i < h.count; i++ {
+		for path := range h.at(i).Values {
+			seen[path] = struct{}{}
+		}
+	}
+
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// at returns the i-th oldest sample. h.mu must be held.
+func (h *History) at(i int) Sample {
+	return h.samples[(h.start+i)%len(h.samples)]
+}
+
+// bounds returns the [first, last) indexes of the samples within [from, to].
+// h.mu must be held.
+func (h *History) bounds(from, to time.Time) (int, int) {
+	first := 0
+	if !from.IsZero() {
+		first = sort.Search(h.count, func(i int) bool { return !h.at(i).Time.Before(from) })
+	}
+
+	last := h.count
+	if !to.IsZero() {
+		last = sort.Search(h.count, func(i int) bool { return h.at(i).Time.After(to) })
+	}
+
+	if last < first {
+		last = first
+	}
+	return first, last
+}
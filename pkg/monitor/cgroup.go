@@ -0,0 +1,457 @@
+package monitor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/types"
+)
+
+// cgroupMaxDepth limits how deep the hierarchy is walked looking for services
+const cgroupMaxDepth = 6
+
+// errCgroupUnavailable is returned on hosts not using the unified cgroup v2 hierarchy
+var errCgroupUnavailable = errors.New("cgroup v2 hierarchy not available")
+
+// containerScopePrefixes maps container scope name prefixes to their runtime
+var containerScopePrefixes = map[string]string{
+	"docker-": "docker",
+	"libpod-": "podman",
+}
+
+// cgroupSample holds the cumulative counters of a cgroup at a point in time
+type cgroupSample struct {
+	usageUsec  uint64
+	readBytes  uint64
+	writeBytes uint64
+	rxBytes    uint64
+	txBytes    uint64
+	taken      time.Time
+}
+
+// cgroupCollector collects per slice, service and container usage from cgroup v2.
+// CPU, IO and network rates are computed against the previous collection.
+type cgroupCollector struct {
+	hostFS   HostFS
+	root     string
+	procRoot string
+	cpus     int // Logical CPUs of the host, refreshed on every collection
+	prev     map[string]cgroupSample
+	primed   bool // Whether the first sample was taken
+	resolver *containerResolver
+}
+
+// containerCgroup is a container scope found while walking the hierarchy
+type containerCgroup struct {
+	path    string
+	id      string
+	runtime string
+}
+
+// newCgroupCollector creates a collector reading the hierarchy under the sysfs
+// root of hostFS
+func newCgroupCollector(hostFS HostFS) *cgroupCollector {
+	return &cgroupCollector{
+		hostFS:   hostFS,
+		root:     hostFS.SysPath("fs", "cgroup"),
+		procRoot: hostFS.Proc,
+		prev:     make(map[string]cgroupSample),
+		resolver: newContainerResolver(),
+	}
+}
+
+// collect walks the hierarchy and returns services, top-level slices and
+// containers, each sorted by consumption. Slices include the usage of their
+// services, so they are kept apart to not be ranked or summed along with them.
+func (c *cgroupCollector) collect() ([]*types.ServiceMetrics, []*types.ServiceMetrics, []*types.ContainerMetrics, error) {
+	if _, err := os.Stat(filepath.Join(c.root, "cgroup.controllers")); err != nil {
+		return nil, nil, nil, errCgroupUnavailable
+	}
+
+	slicePaths, servicePaths, containerCgroups, err := c.walk()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	now := time.Now()
+	c.cpus = getCPUCount(c.hostFS.context())
+	current := make(map[string]cgroupSample, len(slicePaths)+len(servicePaths)+len(containerCgroups))
+
+	readServices := func(paths []string) []*types.ServiceMetrics {
+		services := make([]*types.ServiceMetrics, 0, len(paths))
+		for _, rel := range paths {
+			service, sample := c.readService(rel, now)
+			current[rel] = sample
+			services = append(services, service)
+		}
+		sortServices(services)
+		return services
+	}
+	services := readServices(servicePaths)
+	slices := readServices(slicePaths)
+
+	containers := make([]*types.ContainerMetrics, 0, len(containerCgroups))
+	active := make(map[string]bool, len(containerCgroups))
+	for _, found := range containerCgroups {
+		container, sample := c.readContainer(found, now)
+		current[found.path] = sample
+		containers = append(containers, container)
+		active[found.id] = true
+	}
+	c.resolver.prune(active)
+
+	c.prev = current
+	sortContainers(containers)
+	return services, slices, containers, nil
+}
+
+// prime takes a first sample so the next collection can compute rates. It
+// only runs once, failures being reported by the collections themselves.
+func (c *cgroupCollector) prime() {
+	if c.primed {
+		return
+	}
+	c.primed = true
+
+	if _, _, _, err := c.collect(); err != nil && !errors.Is(err, errCgroupUnavailable) {
+		logs.Debug("Failed to take the first cgroup sample: %v", err)
+	}
+}
+
+// walk returns the top-level slices and scopes, every service and every
+// container scope
+func (c *cgroupCollector) walk() ([]string, []string, []containerCgroup, error) {
+	var slices, services []string
+	var containers []containerCgroup
+
+	err := filepath.WalkDir(c.root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			// Cgroups can disappear while walking, skip them
+			if path == c.root {
+				return err
+			}
+			return filepath.SkipDir
+		}
+		if !entry.IsDir() || path == c.root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(c.root, path)
+		if err != nil {
+			return filepath.SkipDir
+		}
+
+		depth := strings.Count(rel, string(filepath.Separator)) + 1
+		if depth > cgroupMaxDepth {
+			return filepath.SkipDir
+		}
+
+		name := entry.Name()
+		if id, containerRuntime, ok := parseContainerScope(name); ok {
+			containers = append(containers, containerCgroup{path: rel, id: id, runtime: containerRuntime})
+			// Container internals are not services of the host
+			return filepath.SkipDir
+		}
+
+		switch {
+		case depth == 1 && (strings.HasSuffix(name, ".slice") || strings.HasSuffix(name, ".scope")):
+			slices = append(slices, rel)
+		case strings.HasSuffix(name, ".service"):
+			services = append(services, rel)
+		}
+
+		return nil
+	})
+
+	return slices, services, containers, err
+}
+
+// readService reads the usage of a slice or service cgroup and derives its rates
+func (c *cgroupCollector) readService(rel string, now time.Time) (*types.ServiceMetrics, cgroupSample) {
+	service := &types.ServiceMetrics{
+		Name: filepath.Base(rel),
+		Path: rel,
+	}
+
+	sample, memory := c.readCgroup(rel, now)
+	service.MemoryBytes = memory
+	service.IOReadBytes = sample.readBytes
+	service.IOWriteBytes = sample.writeBytes
+
+	prev, elapsed, ok := c.previous(rel, now)
+	if !ok {
+		return service, sample
+	}
+
+	service.CPUPercent = cpuPercent(prev.usageUsec, sample.usageUsec, elapsed, c.cpus)
+	service.IOReadRate = rate(prev.readBytes, sample.readBytes, elapsed)
+	service.IOWriteRate = rate(prev.writeBytes, sample.writeBytes, elapsed)
+	return service, sample
+}
+
+// readContainer reads the usage of a container scope and derives its rates
+func (c *cgroupCollector) readContainer(found containerCgroup, now time.Time) (*types.ContainerMetrics, cgroupSample) {
+	container := &types.ContainerMetrics{
+		ID:      found.id,
+		Name:    c.resolver.name(found.runtime, found.id),
+		Runtime: found.runtime,
+	}
+
+	sample, memory := c.readCgroup(found.path, now)
+	container.MemoryBytes = memory
+
+	if pid, err := findCgroupPID(filepath.Join(c.root, found.path)); err == nil {
+		netDev := filepath.Join(c.procRoot, pid, "net", "dev")
+		if rx, tx, err := readNetDev(netDev); err == nil {
+			sample.rxBytes = rx
+			sample.txBytes = tx
+		}
+	}
+
+	container.NetRxBytes = sample.rxBytes
+	container.NetTxBytes = sample.txBytes
+
+	prev, elapsed, ok := c.previous(found.path, now)
+	if !ok {
+		return container, sample
+	}
+
+	container.CPUPercent = cpuPercent(prev.usageUsec, sample.usageUsec, elapsed, c.cpus)
+	container.NetRxRate = rate(prev.rxBytes, sample.rxBytes, elapsed)
+	container.NetTxRate = rate(prev.txBytes, sample.txBytes, elapsed)
+	return container, sample
+}
+
+// readCgroup reads the cumulative counters and current memory usage of a cgroup
+func (c *cgroupCollector) readCgroup(rel string, now time.Time) (cgroupSample, uint64) {
+	dir := filepath.Join(c.root, rel)
+	sample := cgroupSample{taken: now}
+
+	if usage, err := readCgroupKeyedValue(filepath.Join(dir, "cpu.stat"), "usage_usec"); err == nil {
+		sample.usageUsec = usage
+	}
+	if read, write, err := readCgroupIOStat(filepath.Join(dir, "io.stat")); err == nil {
+		sample.readBytes = read
+		sample.writeBytes = write
+	}
+
+	var memory uint64
+	if current, err := readCgroupValue(filepath.Join(dir, "memory.current")); err == nil {
+		memory = current
+	}
+
+	return sample, memory
+}
+
+// previous returns the previous sample of a cgroup and the seconds elapsed since
+func (c *cgroupCollector) previous(rel string, now time.Time) (cgroupSample, float64, bool) {
+	prev, ok := c.prev[rel]
+	if !ok {
+		return prev, 0, false
+	}
+
+	elapsed := now.Sub(prev.taken).Seconds()
+	if elapsed <= 0 {
+		return prev, 0, false
+	}
+
+	return prev, elapsed, true
+}
+
+// cpuPercent converts a usage_usec delta into a percentage of the total
+// capacity of cpus CPUs
+func cpuPercent(prev, current uint64, elapsed float64, cpus int) float64 {
+	if current < prev || cpus < 1 {
+		return 0
+	}
+
+	cpuSeconds := float64(current-prev) / 1e6
+	return cpuSeconds / elapsed / float64(cpus) * 100
+}
+
+// rate converts a cumulative counter delta into a per second rate
+func rate(prev, current uint64, elapsed float64) float64 {
+	if current < prev {
+		return 0
+	}
+
+	return float64(current-prev) / elapsed
+}
+
+// sortServices orders services by CPU usage, then memory usage
+func sortServices(services []*types.ServiceMetrics) {
+	sort.SliceStable(services, func(i, j int) bool {
+		if services[i].CPUPercent != services[j].CPUPercent {
+			return services[i].CPUPercent > services[j].CPUPercent
+		}
+		return services[i].MemoryBytes > services[j].MemoryBytes
+	})
+}
+
+// sortContainers orders containers by CPU usage, then memory usage
+func sortContainers(containers []*types.ContainerMetrics) {
+	sort.SliceStable(containers, func(i, j int) bool {
+		if containers[i].CPUPercent != containers[j].CPUPercent {
+			return containers[i].CPUPercent > containers[j].CPUPercent
+		}
+		return containers[i].MemoryBytes > containers[j].MemoryBytes
+	})
+}
+
+// parseContainerScope extracts the container ID and runtime from a scope name
+// such as "docker-<id>.scope" or "libpod-<id>.scope"
+func parseContainerScope(name string) (string, string, bool) {
+	if !strings.HasSuffix(name, ".scope") {
+		return "", "", false
+	}
+
+	for prefix, containerRuntime := range containerScopePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			id := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".scope")
+			// Conmon and other helper scopes are not containers
+			if id == "" || strings.Contains(id, "-") {
+				return "", "", false
+			}
+			return id, containerRuntime, true
+		}
+	}
+
+	return "", "", false
+}
+
+// findCgroupPID returns the first process in a cgroup or any of its children
+func findCgroupPID(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err == nil {
+		if pid, _, _ := strings.Cut(strings.TrimSpace(string(data)), "\n"); pid != "" {
+			return pid, nil
+		}
+	}
+
+	// With cgroup v2 processes only live in leaf cgroups
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if pid, err := findCgroupPID(filepath.Join(dir, entry.Name())); err == nil {
+			return pid, nil
+		}
+	}
+
+	return "", fmt.Errorf("no process found in %s", dir)
+}
+
+// readNetDev sums received and transmitted bytes over all non loopback
+// interfaces listed in a /proc/<pid>/net/dev file
+func readNetDev(path string) (uint64, uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	var rx, tx uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Interface lines look like "  eth0: <rx bytes> <7 rx fields> <tx bytes> ..."
+		iface, counters, found := strings.Cut(scanner.Text(), ":")
+		if !found || strings.TrimSpace(iface) == "lo" {
+			continue
+		}
+
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			continue
+		}
+
+		if n, err := strconv.ParseUint(fields[0], 10, 64); err == nil {
+			rx += n
+		}
+		if n, err := strconv.ParseUint(fields[8], 10, 64); err == nil {
+			tx += n
+		}
+	}
+
+	return rx, tx, scanner.Err()
+}
+
+// readCgroupValue reads a single value file such as memory.current
+func readCgroupValue(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// readCgroupKeyedValue reads a key from a flat keyed file such as cpu.stat
+func readCgroupKeyedValue(path, key string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, fmt.Errorf("key %s not found in %s", key, path)
+}
+
+// readCgroupIOStat sums read and written bytes over all devices in io.stat
+func readCgroupIOStat(path string) (uint64, uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	var read, write uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Each line looks like "8:0 rbytes=1 wbytes=2 rios=3 wios=4 dbytes=0 dios=0"
+		for _, field := range strings.Fields(scanner.Text()) {
+			key, value, found := strings.Cut(field, "=")
+			if !found {
+				continue
+			}
+
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+
+			switch key {
+			case "rbytes":
+				read += n
+			case "wbytes":
+				write += n
+			}
+		}
+	}
+
+	return read, write, scanner.Err()
+}
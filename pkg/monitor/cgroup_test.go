@@ -0,0 +1,159 @@
+package monitor
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"p-monitor/pkg/types"
+)
+
+// fixtureContainerID is the Docker container recorded in testdata/desktop
+const fixtureContainerID = "4f3b2a1c0d9e8f7a6b5c4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a"
+
+func TestCgroupCollectorServices(t *testing.T) {
+	c := newCgroupCollector(fixtureHostFS("desktop"))
+	c.resolver.names[fixtureContainerID] = "web"
+
+	services, slices, _, err := c.collect()
+	if err != nil {
+		t.Fatalf("collect() failed: %v", err)
+	}
+
+	// Without a previous collection there are no rates, so services and
+	// slices are sorted by memory, each on their own
+	for _, tt := range []struct {
+		kind string
+		got  []*types.ServiceMetrics
+		want []types.ServiceMetrics
+	}{
+		{
+			kind: "service",
+			got:  services,
+			want: []types.ServiceMetrics{
+				{Name: "user@1000.service", Path: "user.slice/user-1000.slice/user@1000.service", MemoryBytes: 2500000000},
+				{Name: "nginx.service", Path: "system.slice/nginx.service", MemoryBytes: 52000000, IOReadBytes: 8192, IOWriteBytes: 16384},
+				{Name: "dbus.service", Path: "system.slice/dbus.service", MemoryBytes: 4000000, IOReadBytes: 4096},
+				{Name: "dbus.service", Path: "user.slice/user-1000.slice/user@1000.service/session.slice/dbus.service", MemoryBytes: 3000000},
+			},
+		},
+		{
+			kind: "slice",
+			got:  slices,
+			want: []types.ServiceMetrics{
+				{Name: "user.slice", Path: "user.slice", MemoryBytes: 3000000000},
+				{Name: "system.slice", Path: "system.slice", MemoryBytes: 800000000, IOReadBytes: 40960, IOWriteBytes: 81920},
+				{Name: "init.scope", Path: "init.scope", MemoryBytes: 10000000},
+			},
+		},
+	} {
+		if len(tt.got) != len(tt.want) {
+			t.Fatalf("collect() returned %d %ss, want %d", len(tt.got), tt.kind, len(tt.want))
+		}
+		for i, service := range tt.got {
+			if *service != tt.want[i] {
+				t.Errorf("%s %d = %+v, want %+v", tt.kind, i, *service, tt.want[i])
+			}
+		}
+	}
+	if c.cpus != 4 {
+		t.Errorf("collector uses %d CPUs, want the 4 of the host", c.cpus)
+	}
+
+	// Both dbus.service units keep their own metric paths
+	values := (&types.SystemMetrics{Services: services}).Values()
+	for path, want := range map[string]float64{
+		"services[system.slice/dbus.service].memory_bytes":                                               4000000,
+		"services[user.slice/user-1000.slice/user@1000.service/session.slice/dbus.service].memory_bytes": 3000000,
+	} {
+		if got, ok := values[path]; !ok || got != want {
+			t.Errorf("values[%q] = %v, %v, want %v", path, got, ok, want)
+		}
+	}
+}
+
+func TestCgroupCollectorContainers(t *testing.T) {
+	c := newCgroupCollector(fixtureHostFS("desktop"))
+	c.resolver.names[fixtureContainerID] = "web"
+
+	_, _, containers, err := c.collect()
+	if err != nil {
+		t.Fatalf("collect() failed: %v", err)
+	}
+
+	want := types.ContainerMetrics{
+		ID:          fixtureContainerID,
+		Name:        "web",
+		Runtime:     "docker",
+		MemoryBytes: 120000000,
+		NetRxBytes:  73000, // Loopback traffic is left out
+		NetTxBytes:  21000,
+	}
+	if len(containers) != 1 || *containers[0] != want {
+		t.Fatalf("collect() containers = %+v, want [%+v]", containers, want)
+	}
+}
+
+func TestCgroupCollectorRates(t *testing.T) {
+	c := newCgroupCollector(fixtureHostFS("desktop"))
+	c.resolver.names[fixtureContainerID] = "web"
+
+	// A previous collection 2 seconds ago, 4 CPU seconds less for nginx
+	c.prev = map[string]cgroupSample{
+		"system.slice/nginx.service": {usageUsec: 3000000 - 4000000/2, readBytes: 0, writeBytes: 16384 - 8192, taken: time.Now().Add(-2 * time.Second)},
+	}
+
+	services, slices, _, err := c.collect()
+	if err != nil {
+		t.Fatalf("collect() failed: %v", err)
+	}
+
+	for _, service := range append(services, slices...) {
+		if service.Path != "system.slice/nginx.service" {
+			if service.CPUPercent != 0 {
+				t.Errorf("%s has a CPU usage without previous collection", service.Path)
+			}
+			continue
+		}
+
+		// 2 CPU seconds over 2 seconds on 4 CPUs is 25%
+		if math.Abs(service.CPUPercent-25) > 0.5 {
+			t.Errorf("nginx CPU = %v%%, want 25%%", service.CPUPercent)
+		}
+		if math.Abs(service.IOReadRate-4096) > 100 || math.Abs(service.IOWriteRate-4096) > 100 {
+			t.Errorf("nginx IO rates = %v, %v, want 4096, 4096", service.IOReadRate, service.IOWriteRate)
+		}
+	}
+}
+
+func TestCgroupCollectorUnavailable(t *testing.T) {
+	c := newCgroupCollector(fixtureHostFS("server"))
+	if _, _, _, err := c.collect(); !errors.Is(err, errCgroupUnavailable) {
+		t.Errorf("collect() error = %v, want %v", err, errCgroupUnavailable)
+	}
+}
+
+func TestCPUPercent(t *testing.T) {
+	tests := []struct {
+		name          string
+		prev, current uint64
+		elapsed       float64
+		cpus          int
+		want          float64
+	}{
+		{name: "one busy CPU of four", prev: 0, current: 1000000, elapsed: 1, cpus: 4, want: 25},
+		{name: "all CPUs busy", prev: 1000000, current: 9000000, elapsed: 2, cpus: 4, want: 100},
+		{name: "idle", prev: 5000000, current: 5000000, elapsed: 1, cpus: 8, want: 0},
+		{name: "counter reset", prev: 5000000, current: 1000, elapsed: 1, cpus: 8, want: 0},
+		{name: "unknown CPU count", prev: 0, current: 1000000, elapsed: 1, cpus: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cpuPercent(tt.prev, tt.current, tt.elapsed, tt.cpus); got != tt.want {
+				t.Errorf("cpuPercent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"p-monitor/internal/logs"
+)
+
+const (
+	// containerAPITimeout bounds each request made to a container runtime socket
+	containerAPITimeout = 2 * time.Second
+
+	// shortContainerIDLength is the ID length shown when a name can't be resolved
+	shortContainerIDLength = 12
+)
+
+// containerSummary is the subset of the Docker compatible /containers/json
+// response used to resolve container names
+type containerSummary struct {
+	ID    string   `json:"Id"`
+	Names []string `json:"Names"`
+}
+
+// containerResolver resolves container IDs to names using the runtime sockets.
+// Names are cached and the runtime is only queried again for unknown IDs.
+type containerResolver struct {
+	names map[string]string
+}
+
+// newContainerResolver creates a new container name resolver
+func newContainerResolver() *containerResolver {
+	return &containerResolver{
+		names: make(map[string]string),
+	}
+}
+
+// name returns the container name, falling back to the short ID
+func (r *containerResolver) name(containerRuntime, id string) string {
+	if name, ok := r.names[id]; ok {
+		return name
+	}
+
+	r.refresh(containerRuntime)
+
+	if name, ok := r.names[id]; ok {
+		return name
+	}
+
+	// Remember the fallback so unresolvable containers don't query every time
+	short := id
+	if len(short) > shortContainerIDLength {
+		short = short[:shortContainerIDLength]
+	}
+	r.names[id] = short
+	return short
+}
+
+// refresh reloads the names of every container known to the runtime
+func (r *containerResolver) refresh(containerRuntime string) {
+	for _, socket := range containerSockets(containerRuntime) {
+		containers, err := listContainers(socket)
+		if err != nil {
+			logs.Debug("Failed to list containers from %s: %v", socket, err)
+			continue
+		}
+
+		for _, container := range containers {
+			if len(container.Names) > 0 {
+				r.names[container.ID] = strings.TrimPrefix(container.Names[0], "/")
+			}
+		}
+	}
+}
+
+// prune forgets the names of containers that are no longer running
+func (r *containerResolver) prune(active map[string]bool) {
+	for id := range r.names {
+		if !active[id] {
+			delete(r.names, id)
+		}
+	}
+}
+
+// containerSockets returns the API socket paths a runtime may be listening on
+func containerSockets(containerRuntime string) []string {
+	switch containerRuntime {
+	case "docker":
+		return []string{"/var/run/docker.sock"}
+	case "podman":
+		sockets := []string{"/run/podman/podman.sock"}
+		if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+			sockets = append([]string{filepath.Join(runtimeDir, "podman", "podman.sock")}, sockets...)
+		}
+		return sockets
+	default:
+		return nil
+	}
+}
+
+// listContainers lists running containers through a Docker compatible API socket
+func listContainers(socket string) ([]containerSummary, error) {
+	if _, err := os.Stat(socket); err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout: containerAPITimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	// The host is ignored, requests always go through the socket
+	resp, err := client.Get("http://localhost/containers/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var containers []containerSummary
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("failed to decode container list: %v", err)
+	}
+
+	return containers, nil
+}
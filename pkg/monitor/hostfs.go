@@ -0,0 +1,63 @@
+package monitor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/shirou/gopsutil/v4/common"
+)
+
+// HostFS holds the roots the filesystem based collectors read from.
+// Pointing them at another tree allows monitoring a host from a container
+// or reading a recorded machine layout.
+type HostFS struct {
+	Root string // Mount point of the host root filesystem, used for disk usage
+	Proc string // Mount point of procfs
+	Sys  string // Mount point of sysfs
+	Dev  string // Directory holding the device nodes, used for drive health
+}
+
+// DefaultHostFS returns the live roots, honouring the HOST_ROOT, HOST_PROC,
+// HOST_SYS and HOST_DEV overrides also understood by gopsutil
+func DefaultHostFS() HostFS {
+	return HostFS{
+		Root: getEnv(string(common.HostRootEnvKey), "/"),
+		Proc: getEnv(string(common.HostProcEnvKey), "/proc"),
+		Sys:  getEnv(string(common.HostSysEnvKey), "/sys"),
+		Dev:  getEnv(string(common.HostDevEnvKey), "/dev"),
+	}
+}
+
+// ProcPath joins elements to the procfs root
+func (h HostFS) ProcPath(elem ...string) string {
+	return filepath.Join(append([]string{h.Proc}, elem...)...)
+}
+
+// SysPath joins elements to the sysfs root
+func (h HostFS) SysPath(elem ...string) string {
+	return filepath.Join(append([]string{h.Sys}, elem...)...)
+}
+
+// RootPath joins elements to the host root filesystem
+func (h HostFS) RootPath(elem ...string) string {
+	return filepath.Join(append([]string{h.Root}, elem...)...)
+}
+
+// context returns a context making gopsutil read from the same roots
+func (h HostFS) context() context.Context {
+	return context.WithValue(context.Background(), common.EnvKey, common.EnvMap{
+		common.HostRootEnvKey: h.Root,
+		common.HostProcEnvKey: h.Proc,
+		common.HostSysEnvKey:  h.Sys,
+		common.HostDevEnvKey:  h.Dev,
+	})
+}
+
+// getEnv returns the value of an environment variable or a default
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
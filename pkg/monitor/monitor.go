@@ -2,46 +2,109 @@ package monitor
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"p-monitor/internal/logs"
+	"p-monitor/pkg/command"
 	"p-monitor/pkg/config"
 	"p-monitor/pkg/gpu"
+	"p-monitor/pkg/history"
+	"p-monitor/pkg/replay"
+	"p-monitor/pkg/smart"
 	"p-monitor/pkg/types"
 )
 
 // Use types from the types package
 type SystemMetrics = types.SystemMetrics
 type DiskMetrics = types.DiskMetrics
+type DriveHealth = types.DriveHealth
 type MemoryMetrics = types.MemoryMetrics
 type CPUMetrics = types.CPUMetrics
 type GPUMetrics = types.GPUMetrics
+type NetworkMetrics = types.NetworkMetrics
+type ServiceMetrics = types.ServiceMetrics
+type ContainerMetrics = types.ContainerMetrics
 
 // Monitor handles system monitoring
 type Monitor struct {
-	config  *config.Config
-	metrics chan *SystemMetrics
-	ctx     context.Context
-	cancel  context.CancelFunc
-	latest  *SystemMetrics
+	config      *config.Config
+	ctx         context.Context
+	cancel      context.CancelFunc
+	mu          sync.RWMutex
+	collectMu   sync.Mutex
+	latest      *SystemMetrics
+	subscribers map[chan *SystemMetrics]struct{}
+	reconfigure chan struct{}
+	paused      atomic.Bool
+	history     *history.History
+	hostFS      HostFS
+	cgroups     *cgroupCollector
+	gpus        *gpu.Collector
+	drives      *smart.Collector
+	lastNetwork *NetworkMetrics // Previous network counters, for rates
+	lastNetTime time.Time
+	replay      *replay.Player // Recording emitted instead of collections, if any
+	replaySpeed float64
 }
 
-// New creates a new monitor instance
+// New creates a new monitor instance reading from the live host
 func New(cfg *config.Config) *Monitor {
+	return NewWithHostFS(cfg, DefaultHostFS())
+}
+
+// NewWithHostFS creates a new monitor instance reading procfs and sysfs from the given roots
+func NewWithHostFS(cfg *config.Config, hostFS HostFS) *Monitor {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Monitor{
-		config:  cfg,
-		metrics: make(chan *SystemMetrics, 1),
-		ctx:     ctx,
-		cancel:  cancel,
+		config:      cfg,
+		ctx:         ctx,
+		cancel:      cancel,
+		subscribers: make(map[chan *SystemMetrics]struct{}),
+		reconfigure: make(chan struct{}, 1),
+		history:     history.New(cfg.Snapshot().HistorySize),
+		hostFS:      hostFS,
+		cgroups:     newCgroupCollector(hostFS),
+		gpus:        gpu.NewCollector(command.ExecRunner{}),
+		drives:      smart.NewCollector(command.ExecRunner{}, hostFS.Sys, hostFS.Dev),
+	}
+}
+
+// SetGPUCommandRunner replaces the runner used by the GPU collectors
+func (m *Monitor) SetGPUCommandRunner(runner command.Runner) {
+	m.gpus = gpu.NewCollector(runner)
+}
+
+// SetReplay makes the monitor emit the collections of a recording instead of
+// reading the machine, spaced by their recorded intervals divided by speed
+func (m *Monitor) SetReplay(player *replay.Player, speed float64) {
+	if speed <= 0 {
+		speed = 1
 	}
+	m.replay = player
+	m.replaySpeed = speed
+}
+
+// Replaying reports whether the monitor emits a recording
+func (m *Monitor) Replaying() bool {
+	return m.replay != nil
 }
 
 // Start starts the monitoring loop
 func (m *Monitor) Start() {
+	if m.replay != nil {
+		m.replayLoop()
+		return
+	}
+
 	logs.Info("Starting system monitor")
 
-	ticker := time.NewTicker(time.Duration(m.config.GetUpdateIntervalSeconds()) * time.Second)
+	ticker := time.NewTicker(m.interval())
 	defer ticker.Stop()
 
 	// Initial collection
@@ -50,7 +113,12 @@ func (m *Monitor) Start() {
 	for {
 		select {
 		case <-ticker.C:
-			m.collectMetrics()
+			if !m.Paused() {
+				m.collectMetrics()
+			}
+		case <-m.reconfigure:
+			logs.Info("Update interval set to %s", m.interval())
+			ticker.Reset(m.interval())
 		case <-m.ctx.Done():
 			logs.Info("Stopping system monitor")
 			return
@@ -58,6 +126,113 @@ func (m *Monitor) Start() {
 	}
 }
 
+// Reconfigure applies configuration changes, such as a new update interval
+// or history size, to the running monitor
+func (m *Monitor) Reconfigure() {
+	m.history.Resize(m.config.Snapshot().HistorySize)
+
+	select {
+	case m.reconfigure <- struct{}{}:
+	default:
+		// A reconfiguration is already pending
+	}
+}
+
+// Pause stops scheduled collections until Resume is called
+func (m *Monitor) Pause() {
+	if !m.paused.Swap(true) {
+		logs.Info("Collection paused")
+	}
+}
+
+// Resume restarts scheduled collections stopped by Pause
+func (m *Monitor) Resume() {
+	if m.paused.Swap(false) {
+		logs.Info("Collection resumed")
+	}
+}
+
+// Paused reports whether scheduled collections are paused
+func (m *Monitor) Paused() bool {
+	return m.paused.Load()
+}
+
+// Refresh collects and publishes metrics immediately, even while paused. While
+// replaying it returns the latest replayed collection instead.
+func (m *Monitor) Refresh() *SystemMetrics {
+	if m.replay != nil {
+		if metrics := m.GetMetrics(); metrics != nil {
+			return metrics
+		}
+		return &SystemMetrics{Updated: time.Now()}
+	}
+
+	metrics := m.Collect()
+	m.publish(metrics)
+	return metrics
+}
+
+// replayLoop publishes the collections of the recording until its end,
+// stamping them with the current time so that they pass for live ones
+func (m *Monitor) replayLoop() {
+	logs.Info("Replaying recorded metrics at %gx speed", m.replaySpeed)
+	defer m.replay.Close()
+
+	var previous time.Time
+	for {
+		metrics, err := m.replay.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				logs.Info("Replay finished")
+			} else {
+				logs.Error("Replay stopped: %v", err)
+			}
+			return
+		}
+
+		if !previous.IsZero() {
+			wait := time.Duration(float64(metrics.Updated.Sub(previous)) / m.replaySpeed)
+			if !m.sleep(wait) {
+				return
+			}
+		}
+		previous = metrics.Updated
+
+		// Hold the recording while paused
+		for m.Paused() {
+			if !m.sleep(100 * time.Millisecond) {
+				return
+			}
+		}
+
+		metrics.Updated = time.Now()
+		m.publish(metrics)
+	}
+}
+
+// sleep waits for d, reporting false when the monitor was stopped meanwhile
+func (m *Monitor) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return m.ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-m.ctx.Done():
+		return false
+	}
+}
+
+// interval returns the configured time between collections
+func (m *Monitor) interval() time.Duration {
+	cfg := m.config.Snapshot()
+	return time.Duration(cfg.GetUpdateIntervalSeconds()) * time.Second
+}
+
 // Stop stops the monitoring loop
 func (m *Monitor) Stop() {
 	m.cancel()
@@ -65,47 +240,206 @@ func (m *Monitor) Stop() {
 
 // GetMetrics returns the latest metrics
 func (m *Monitor) GetMetrics() *SystemMetrics {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.latest
 }
 
-// collectMetrics collects all system metrics
+// History returns the samples of the most recent collections
+func (m *Monitor) History() *history.History {
+	return m.history
+}
+
+// Subscribe returns a channel receiving every new collection. Slow subscribers
+// miss updates instead of blocking the monitor, and must call Unsubscribe when done.
+func (m *Monitor) Subscribe() <-chan *SystemMetrics {
+	ch := make(chan *SystemMetrics, 1)
+
+	m.mu.Lock()
+	m.subscribers[ch] = struct{}{}
+	m.mu.Unlock()
+
+	return ch
+}
+
+// Unsubscribe stops delivering collections to a channel returned by Subscribe
+func (m *Monitor) Unsubscribe(sub <-chan *SystemMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for ch := range m.subscribers {
+		if ch == sub {
+			delete(m.subscribers, ch)
+			close(ch)
+			return
+		}
+	}
+}
+
+// collectMetrics collects all system metrics and publishes them
 func (m *Monitor) collectMetrics() {
+	// Update latest metrics and notify subscribers
+	m.publish(m.Collect())
+}
+
+// Collect performs a single collection of all system metrics, recording the
+// duration and error of every collector
+func (m *Monitor) Collect() *SystemMetrics {
+	m.collectMu.Lock()
+	defer m.collectMu.Unlock()
+
 	metrics := &SystemMetrics{
 		Updated: time.Now(),
 	}
 
+	// Sample cgroups early on the first collection so their rates cover the
+	// time spent by the other collectors
+	m.cgroups.prime()
+
 	// Collect disk metrics
-	metrics.Disk = m.collectDiskMetrics()
+	m.track(metrics, "disk", func() string {
+		metrics.Disk = m.collectDiskMetrics()
+		var errMessage string
+		metrics.Mounts, errMessage = m.collectMountMetrics()
+		return joinErrors(metrics.Disk.Error, errMessage)
+	})
+
+	// Collect drive health
+	m.track(metrics, "drives", func() string {
+		var errMessage string
+		metrics.Drives, errMessage = m.collectDriveHealth()
+		return errMessage
+	})
 
 	// Collect memory metrics
-	metrics.Memory = m.collectMemoryMetrics()
+	m.track(metrics, "memory", func() string {
+		metrics.Memory = m.collectMemoryMetrics()
+		return metrics.Memory.Error
+	})
 
 	// Collect CPU metrics
-	metrics.CPU = m.collectCPUMetrics()
+	m.track(metrics, "cpu", func() string {
+		metrics.CPU = m.collectCPUMetrics()
+		return metrics.CPU.Error
+	})
 
 	// Collect GPU metrics
-	metrics.GPUs = m.collectGPUMetrics()
+	m.track(metrics, "gpus", func() string {
+		metrics.GPUs = m.collectGPUMetrics()
+		return gpuErrors(metrics.GPUs)
+	})
+
+	// Collect network metrics
+	m.track(metrics, "network", func() string {
+		metrics.Network = m.collectNetworkMetrics(metrics.Updated)
+		return metrics.Network.Error
+	})
+
+	// Collect per slice, service and container metrics
+	m.track(metrics, "cgroups", func() string {
+		var errMessage string
+		metrics.Services, metrics.Slices, metrics.Containers, errMessage = m.collectCgroupMetrics()
+		return errMessage
+	})
+
+	return metrics
+}
+
+// track runs a collector and records its status in metrics
+func (m *Monitor) track(metrics *SystemMetrics, name string, collect func() string) {
+	start := time.Now()
+	errMessage := collect()
+
+	metrics.Collectors = append(metrics.Collectors, &types.CollectorStatus{
+		Name:     name,
+		Duration: time.Since(start).Seconds(),
+		Error:    errMessage,
+	})
+}
+
+// mountErrors joins the errors of the mounts, e.g. "/home: permission denied"
+func mountErrors(mounts []*DiskMetrics) string {
+	var errs []string
+	for _, mount := range mounts {
+		if mount.Error != "" {
+			errs = append(errs, mount.Mount+": "+mount.Error)
+		}
+	}
+	return strings.Join(errs, "; ")
+}
+
+// joinErrors joins the non-empty error messages
+func joinErrors(messages ...string) string {
+	var errs []string
+	for _, message := range messages {
+		if message != "" {
+			errs = append(errs, message)
+		}
+	}
+	return strings.Join(errs, "; ")
+}
+
+// gpuErrors joins the errors of the GPUs, e.g. "NVIDIA GPU: failed to run
+// nvidia-smi: ...". Integrated GPUs are left out, as their usage is never
+// collected.
+func gpuErrors(gpus []*GPUMetrics) string {
+	var errs []string
+	for _, gpu := range gpus {
+		if gpu.Error != "" && gpu.Type != "integrated" {
+			errs = append(errs, gpu.Name+": "+gpu.Error)
+		}
+	}
+	return strings.Join(errs, "; ")
+}
+
+// publish stores metrics as the latest and sends them to every subscriber
+func (m *Monitor) publish(metrics *SystemMetrics) {
+	m.history.Add(metrics)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	// Update latest metrics
 	m.latest = metrics
 
-	// Send metrics (non-blocking)
-	select {
-	case m.metrics <- metrics:
-	default:
-		// Channel is full, skip this update
+	for ch := range m.subscribers {
+		// Send metrics (non-blocking)
+		select {
+		case ch <- metrics:
+		default:
+			// Channel is full, skip this update
+		}
 	}
 }
 
-// collectDiskMetrics collects disk usage metrics
+// collectDiskMetrics collects disk usage metrics of the root filesystem
 func (m *Monitor) collectDiskMetrics() *DiskMetrics {
-	disk := &DiskMetrics{}
+	return m.collectMountUsage("/")
+}
 
-	// Get root filesystem usage
-	usage, err := getDiskUsage("/")
+// collectMountMetrics collects disk usage metrics of the other writable
+// filesystems, along with the errors of listing or reading them
+func (m *Monitor) collectMountMetrics() ([]*DiskMetrics, string) {
+	mounts, err := getMountPoints(m.hostFS.context())
+	if err != nil {
+		logs.Error("Failed to list mounts: %v", err)
+		return nil, fmt.Sprintf("failed to list mounts: %v", err)
+	}
+
+	disks := make([]*DiskMetrics, 0, len(mounts))
+	for _, mount := range mounts {
+		disks = append(disks, m.collectMountUsage(mount))
+	}
+	return disks, mountErrors(disks)
+}
+
+// collectMountUsage collects disk usage metrics of the filesystem mounted at mount
+func (m *Monitor) collectMountUsage(mount string) *DiskMetrics {
+	disk := &DiskMetrics{Mount: mount}
+
+	usage, err := getDiskUsage(m.hostFS.context(), m.hostFS.RootPath(mount))
 	if err != nil {
 		disk.Error = err.Error()
-		logs.Error("Failed to get disk usage: %v", err)
+		logs.Error("Failed to get disk usage of %s: %v", mount, err)
 		return disk
 	}
 
@@ -115,11 +449,22 @@ func (m *Monitor) collectDiskMetrics() *DiskMetrics {
 	return disk
 }
 
+// collectDriveHealth collects SMART health of physical drives. Drives this
+// system can't read only carry the reason in their entry.
+func (m *Monitor) collectDriveHealth() ([]*DriveHealth, string) {
+	drives, err := m.drives.CollectAll()
+	if err != nil {
+		logs.Error("Failed to get drive health: %v", err)
+		return drives, err.Error()
+	}
+	return drives, ""
+}
+
 // collectMemoryMetrics collects memory usage metrics
 func (m *Monitor) collectMemoryMetrics() *MemoryMetrics {
 	memory := &MemoryMetrics{}
 
-	usage, err := getMemoryUsage()
+	usage, err := getMemoryUsage(m.hostFS.context())
 	if err != nil {
 		memory.Error = err.Error()
 		logs.Error("Failed to get memory usage: %v", err)
@@ -132,21 +477,50 @@ func (m *Monitor) collectMemoryMetrics() *MemoryMetrics {
 	return memory
 }
 
+// collectNetworkMetrics collects network traffic, computing rates from the
+// previous collection
+func (m *Monitor) collectNetworkMetrics(now time.Time) *NetworkMetrics {
+	network := &NetworkMetrics{}
+
+	rx, tx, err := getNetworkCounters(m.hostFS.context())
+	if err != nil {
+		network.Error = err.Error()
+		logs.Error("Failed to get network counters: %v", err)
+		return network
+	}
+
+	network.RxBytes = rx
+	network.TxBytes = tx
+
+	// Counters that went backwards were reset, e.g. by an interface going away
+	if last := m.lastNetwork; last != nil && rx >= last.RxBytes && tx >= last.TxBytes {
+		if elapsed := now.Sub(m.lastNetTime).Seconds(); elapsed > 0 {
+			network.RxRate = float64(rx-last.RxBytes) / elapsed
+			network.TxRate = float64(tx-last.TxBytes) / elapsed
+		}
+	}
+
+	m.lastNetwork = network
+	m.lastNetTime = now
+	return network
+}
+
 // collectCPUMetrics collects CPU usage and temperature metrics
 func (m *Monitor) collectCPUMetrics() *CPUMetrics {
 	cpu := &CPUMetrics{}
 
 	// Get CPU usage
-	usage, err := getCPUUsage()
+	usage, cores, err := getCPUUsage(m.hostFS.context())
 	if err != nil {
 		cpu.Error = err.Error()
 		logs.Error("Failed to get CPU usage: %v", err)
 		return cpu
 	}
 	cpu.UsagePercent = usage
+	cpu.CoreUsagePercent = cores
 
 	// Get CPU temperature
-	temp, err := getCPUTemperature()
+	temp, err := getCPUTemperature(m.hostFS)
 	if err != nil {
 		logs.Error("Failed to get CPU temperature: %v", err)
 		// Don't set error for temperature as it's optional
@@ -159,5 +533,21 @@ func (m *Monitor) collectCPUMetrics() *CPUMetrics {
 
 // collectGPUMetrics collects GPU usage and temperature metrics
 func (m *Monitor) collectGPUMetrics() []*GPUMetrics {
-	return gpu.CollectAllGPUMetrics()
+	return m.gpus.CollectAll()
+}
+
+// collectCgroupMetrics collects per slice, service and container cgroup metrics.
+// Hosts without cgroup v2 simply have no services, slices or containers to report.
+func (m *Monitor) collectCgroupMetrics() ([]*ServiceMetrics, []*ServiceMetrics, []*ContainerMetrics, string) {
+	services, slices, containers, err := m.cgroups.collect()
+	if errors.Is(err, errCgroupUnavailable) {
+		logs.Debug("Skipping cgroup metrics: %v", err)
+		return nil, nil, nil, ""
+	}
+	if err != nil {
+		logs.Error("Failed to get cgroup metrics: %v", err)
+		return nil, nil, nil, err.Error()
+	}
+
+	return services, slices, containers, ""
 }
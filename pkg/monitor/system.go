@@ -1,9 +1,12 @@
 package monitor
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -11,51 +14,111 @@ import (
 	"github.com/shirou/gopsutil/v4/cpu"
 	"github.com/shirou/gopsutil/v4/disk"
 	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/shirou/gopsutil/v4/net"
 )
 
 // getDiskUsage gets disk usage for the root filesystem
-func getDiskUsage(path string) (*disk.UsageStat, error) {
-	return disk.Usage(path)
+func getDiskUsage(ctx context.Context, path string) (*disk.UsageStat, error) {
+	return disk.UsageWithContext(ctx, path)
+}
+
+// getMountPoints returns the mount points of the writable filesystems backed
+// by a device other than "/", listing each device once
+func getMountPoints(ctx context.Context) ([]string, error) {
+	partitions, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var mounts []string
+	for _, partition := range partitions {
+		// Read-only filesystems, such as snap images, can't fill up
+		if slices.Contains(partition.Opts, "ro") || seen[partition.Device] {
+			continue
+		}
+		seen[partition.Device] = true
+		if partition.Mountpoint != "/" {
+			mounts = append(mounts, partition.Mountpoint)
+		}
+	}
+	return mounts, nil
 }
 
 // getMemoryUsage gets system memory usage
-func getMemoryUsage() (*mem.VirtualMemoryStat, error) {
-	return mem.VirtualMemory()
+func getMemoryUsage(ctx context.Context) (*mem.VirtualMemoryStat, error) {
+	return mem.VirtualMemoryWithContext(ctx)
 }
 
-// getCPUUsage gets CPU usage percentage
-func getCPUUsage() (float64, error) {
-	percentages, err := cpu.Percent(time.Second, false)
+// getNetworkCounters gets the bytes received and transmitted by all network
+// interfaces except loopback
+func getNetworkCounters(ctx context.Context) (uint64, uint64, error) {
+	counters, err := net.IOCountersWithContext(ctx, true)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
+	}
+
+	var rx, tx uint64
+	for _, counter := range counters {
+		if counter.Name == "lo" {
+			continue
+		}
+		rx += counter.BytesRecv
+		tx += counter.BytesSent
+	}
+	return rx, tx, nil
+}
+
+// getCPUUsage gets the total CPU usage percentage and the usage of each core,
+// sampled over the same second
+func getCPUUsage(ctx context.Context) (float64, []float64, error) {
+	percentages, err := cpu.PercentWithContext(ctx, time.Second, true)
+	if err != nil {
+		return 0, nil, err
 	}
 
 	if len(percentages) == 0 {
-		return 0, fmt.Errorf("no CPU usage data available")
+		return 0, nil, fmt.Errorf("no CPU usage data available")
 	}
 
-	return percentages[0], nil
+	// Every core has the same capacity, so the total is their average
+	var total float64
+	for _, percent := range percentages {
+		total += percent
+	}
+
+	return total / float64(len(percentages)), percentages, nil
+}
+
+// getCPUCount returns the number of logical CPUs of the monitored host, which
+// differs from the CPUs of this process when HOST_PROC points at another host
+func getCPUCount(ctx context.Context) int {
+	count, err := cpu.CountsWithContext(ctx, true)
+	if err != nil || count < 1 {
+		return runtime.NumCPU()
+	}
+	return count
 }
 
 // getCPUTemperature gets CPU temperature from thermal sensors
-func getCPUTemperature() (float64, error) {
+func getCPUTemperature(hostFS HostFS) (float64, error) {
 	// Try to read from thermal sensors
-	// Common paths for CPU temperature on Linux
+	// Common paths for CPU temperature on Linux, relative to the sysfs root
 	thermalPaths := []string{
-		"/sys/class/thermal/thermal_zone0/temp",                     // Most common
-		"/sys/class/thermal/thermal_zone1/temp",                     // Alternative
-		"/sys/devices/platform/coretemp.0/hwmon/hwmon*/temp1_input", // Intel
-		"/sys/devices/virtual/thermal/thermal_zone0/temp",           // Virtual thermal
+		"class/thermal/thermal_zone0/temp",                     // Most common
+		"class/thermal/thermal_zone1/temp",                     // Alternative
+		"devices/platform/coretemp.0/hwmon/hwmon*/temp1_input", // Intel
+		"devices/virtual/thermal/thermal_zone0/temp",           // Virtual thermal
 	}
 
 	for _, path := range thermalPaths {
-		if temp, err := readThermalFile(path); err == nil {
+		if temp, err := readThermalFile(hostFS.SysPath(path)); err == nil {
 			return temp, nil
 		}
 	}
 
 	// Try to find thermal zones dynamically
-	if temp, err := findThermalZone(); err == nil {
+	if temp, err := findThermalZone(hostFS); err == nil {
 		return temp, nil
 	}
 
@@ -90,8 +153,8 @@ func readThermalFile(path string) (float64, error) {
 }
 
 // findThermalZone finds and reads from available thermal zones
-func findThermalZone() (float64, error) {
-	thermalDir := "/sys/class/thermal"
+func findThermalZone(hostFS HostFS) (float64, error) {
+	thermalDir := hostFS.SysPath("class", "thermal")
 	entries, err := os.ReadDir(thermalDir)
 	if err != nil {
 		return 0, err
@@ -118,19 +181,21 @@ func findThermalZone() (float64, error) {
 	return 0, fmt.Errorf("no CPU thermal zone found")
 }
 
-// Public functions for testing
+// Public functions for testing, reading from the default host roots
 func GetDiskUsage(path string) (*disk.UsageStat, error) {
-	return getDiskUsage(path)
+	hostFS := DefaultHostFS()
+	return getDiskUsage(hostFS.context(), hostFS.RootPath(path))
 }
 
 func GetMemoryUsage() (*mem.VirtualMemoryStat, error) {
-	return getMemoryUsage()
+	return getMemoryUsage(DefaultHostFS().context())
 }
 
 func GetCPUUsage() (float64, error) {
-	return getCPUUsage()
+	total, _, err := getCPUUsage(DefaultHostFS().context())
+	return total, err
 }
 
 func GetCPUTemperature() (float64, error) {
-	return getCPUTemperature()
+	return getCPUTemperature(DefaultHostFS())
 }
@@ -0,0 +1,220 @@
+package monitor
+
+import (
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// fixtureHostFS returns the roots of a host recorded under testdata. The
+// server tree is captured on a single-CPU VM, trimmed to the files read; the
+// meminfo and stat of the desktop tree are the captures of a 4-core Intel
+// machine from gopsutil's testdata (BSD license).
+func fixtureHostFS(host string) HostFS {
+	root := filepath.Join("testdata", host)
+	return HostFS{
+		Root: root,
+		Proc: filepath.Join(root, "proc"),
+		Sys:  filepath.Join(root, "sys"),
+		Dev:  filepath.Join(root, "dev"),
+	}
+}
+
+func TestDefaultHostFS(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want HostFS
+	}{
+		{
+			name: "live host",
+			want: HostFS{Root: "/", Proc: "/proc", Sys: "/sys", Dev: "/dev"},
+		},
+		{
+			name: "host mounted in a container",
+			env:  map[string]string{"HOST_ROOT": "/host", "HOST_PROC": "/host/proc", "HOST_SYS": "/host/sys", "HOST_DEV": "/host/dev"},
+			want: HostFS{Root: "/host", Proc: "/host/proc", Sys: "/host/sys", Dev: "/host/dev"},
+		},
+		{
+			name: "only procfs overridden",
+			env:  map[string]string{"HOST_PROC": "/host/proc"},
+			want: HostFS{Root: "/", Proc: "/host/proc", Sys: "/sys", Dev: "/dev"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"HOST_ROOT", "HOST_PROC", "HOST_SYS", "HOST_DEV"} {
+				t.Setenv(key, tt.env[key])
+			}
+			if got := DefaultHostFS(); got != tt.want {
+				t.Errorf("DefaultHostFS() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostFSPaths(t *testing.T) {
+	hostFS := HostFS{Root: "/host", Proc: "/host/proc", Sys: "/host/sys"}
+
+	tests := []struct {
+		got, want string
+	}{
+		{hostFS.ProcPath("1", "mountinfo"), "/host/proc/1/mountinfo"},
+		{hostFS.SysPath("class", "thermal"), "/host/sys/class/thermal"},
+		{hostFS.RootPath("/home"), "/host/home"},
+		{hostFS.RootPath("/"), "/host"},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
+
+func TestGetMemoryUsage(t *testing.T) {
+	tests := []struct {
+		host        string
+		total, used uint64
+		usedPercent float64
+	}{
+		// Used is MemTotal - MemAvailable
+		{host: "desktop", total: 16502300672, used: 5006942208, usedPercent: 30.340873721295385},
+		{host: "server", total: 6305947648, used: 562802688, usedPercent: 8.924950212336428},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			usage, err := getMemoryUsage(fixtureHostFS(tt.host).context())
+			if err != nil {
+				t.Fatalf("getMemoryUsage() failed: %v", err)
+			}
+			if usage.Total != tt.total || usage.Used != tt.used {
+				t.Errorf("total, used = %d, %d, want %d, %d", usage.Total, usage.Used, tt.total, tt.used)
+			}
+			if math.Abs(usage.UsedPercent-tt.usedPercent) > 1e-9 {
+				t.Errorf("used percent = %v, want %v", usage.UsedPercent, tt.usedPercent)
+			}
+		})
+	}
+}
+
+func TestGetNetworkCounters(t *testing.T) {
+	tests := []struct {
+		host   string
+		rx, tx uint64
+	}{
+		// Loopback traffic is left out
+		{host: "desktop", rx: 6500000, tx: 2500000},
+		{host: "server", rx: 99734675, tx: 554635},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			rx, tx, err := getNetworkCounters(fixtureHostFS(tt.host).context())
+			if err != nil {
+				t.Fatalf("getNetworkCounters() failed: %v", err)
+			}
+			if rx != tt.rx || tx != tt.tx {
+				t.Errorf("rx, tx = %d, %d, want %d, %d", rx, tx, tt.rx, tt.tx)
+			}
+		})
+	}
+}
+
+func TestGetCPUCount(t *testing.T) {
+	tests := []struct {
+		host string
+		want int
+	}{
+		{host: "desktop", want: 4}, // From stat, without cpuinfo
+		{host: "server", want: 1},  // From cpuinfo
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := getCPUCount(fixtureHostFS(tt.host).context()); got != tt.want {
+				t.Errorf("getCPUCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCPUUsage(t *testing.T) {
+	tests := []struct {
+		host  string
+		cores int
+	}{
+		{host: "desktop", cores: 4},
+		{host: "server", cores: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			t.Parallel()
+
+			// Both samples read the same recorded stat, so no CPU time passes between them
+			total, cores, err := getCPUUsage(fixtureHostFS(tt.host).context())
+			if err != nil {
+				t.Fatalf("getCPUUsage() failed: %v", err)
+			}
+			if len(cores) != tt.cores {
+				t.Fatalf("got %d cores, want %d", len(cores), tt.cores)
+			}
+			if total != 0 || slices.ContainsFunc(cores, func(percent float64) bool { return percent != 0 }) {
+				t.Errorf("getCPUUsage() = %v, %v, want 0 on every core", total, cores)
+			}
+		})
+	}
+}
+
+func TestGetCPUTemperature(t *testing.T) {
+	tests := []struct {
+		name    string
+		hostFS  HostFS
+		want    float64
+		wantErr bool
+	}{
+		{name: "thermal zone", hostFS: fixtureHostFS("desktop"), want: 45},
+		{name: "coretemp hwmon", hostFS: fixtureHostFS("server"), want: 61.5},
+		{name: "no sensor", hostFS: HostFS{Root: t.TempDir(), Proc: t.TempDir(), Sys: t.TempDir()}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getCPUTemperature(tt.hostFS)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getCPUTemperature() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getCPUTemperature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMountPoints(t *testing.T) {
+	tests := []struct {
+		host string
+		want []string
+	}{
+		// "/" is the disk metrics, pseudo and read-only filesystems are left
+		// out and /srv shares the device of /home
+		{host: "desktop", want: []string{"/home", "/boot/efi"}},
+		{host: "server", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got, err := getMountPoints(fixtureHostFS(tt.host).context())
+			if err != nil {
+				t.Fatalf("getMountPoints() failed: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("getMountPoints() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"p-monitor/internal/assets"
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/alerting"
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/forecast"
+	"p-monitor/pkg/types"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	// Well-known name, path and interface of the freedesktop notification server
+	notificationsName      = "org.freedesktop.Notifications"
+	notificationsPath      = dbus.ObjectPath("/org/freedesktop/Notifications")
+	notificationsInterface = "org.freedesktop.Notifications"
+
+	// cooldown is how long after notifying an alert firing it is not notified
+	// again, so that a flapping metric doesn't flood the desktop
+	cooldown = 15 * time.Minute
+
+	// Action keys of the notification buttons
+	actionAcknowledge = "acknowledge"
+	actionSnooze      = "snooze"
+	actionHistory     = "history"
+)
+
+// Notifier shows desktop notifications for alert transitions and disk or
+// memory forecasts through the freedesktop notification server on the session bus
+type Notifier struct {
+	engine      *alerting.Engine
+	forecaster  *forecast.Forecaster // Optional
+	config      *config.Config
+	openHistory func() // Runs the "Open history" action, nil to leave it out
+
+	mu       sync.Mutex
+	ids      map[string]uint32         // Notification of every alert, replaced on updates
+	keys     map[uint32]string         // Alert of every notification, for actions
+	alerts   map[uint32]alerting.Alert // Alert of every firing notification, for acknowledging and snoozing
+	notified map[string]time.Time      // Last firing notification of every alert
+}
+
+// New creates a notifier. Without a forecaster, only alerts are notified.
+// openHistory runs when the "Open history" button is clicked; without it the
+// button is left out.
+func New(engine *alerting.Engine, forecaster *forecast.Forecaster, cfg *config.Config, openHistory func()) *Notifier {
+	return &Notifier{
+		engine:      engine,
+		forecaster:  forecaster,
+		config:      cfg,
+		openHistory: openHistory,
+		ids:         make(map[string]uint32),
+		keys:        make(map[uint32]string),
+		alerts:      make(map[uint32]alerting.Alert),
+		notified:    make(map[string]time.Time),
+	}
+}
+
+// Serve connects to the session bus and notifies alert transitions until ctx
+// is cancelled
+func (n *Notifier) Serve(ctx context.Context) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to session bus: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(notificationsPath),
+		dbus.WithMatchInterface(notificationsInterface),
+	); err != nil {
+		return fmt.Errorf("failed to watch notification signals: %v", err)
+	}
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	events := n.engine.Subscribe()
+	defer n.engine.Unsubscribe(events)
+
+	// A nil channel never receives, leaving forecasts out
+	var forecasts <-chan forecast.Event
+	if n.forecaster != nil {
+		forecasts = n.forecaster.Subscribe()
+		defer n.forecaster.Unsubscribe(forecasts)
+	}
+
+	server := conn.Object(notificationsName, notificationsPath)
+	logs.Info("Sending alert notifications to the desktop")
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if !n.config.Snapshot().Notifications {
+				continue
+			}
+			n.notify(server, event)
+		case event, ok := <-forecasts:
+			if !ok {
+				return nil
+			}
+			if !n.config.Snapshot().Notifications {
+				continue
+			}
+			n.notifyForecast(server, event)
+		case signal := <-signals:
+			n.handleSignal(signal)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// notify shows the notification of an alert transition, unless the alert is
+// acknowledged, snoozed or was notified within the cooldown. Pending alerts
+// are not notified.
+func (n *Notifier) notify(server dbus.BusObject, event alerting.Event) {
+	alert := event.Alert
+	key := alertKey(alert)
+	now := time.Now()
+
+	if alert.Acknowledged || !alert.SnoozedUntil.IsZero() {
+		return
+	}
+
+	n.mu.Lock()
+
+	var summary string
+	switch alert.State {
+	case alerting.StateFiring:
+		if last, ok := n.notified[key]; ok && now.Sub(last) < cooldown {
+			n.mu.Unlock()
+			logs.Debug("Not notifying alert %s on %s again within the cooldown", alert.Rule, alert.Path)
+			return
+		}
+		n.notified[key] = now
+		summary = fmt.Sprintf("%s: %s", title(alert.Severity), alert.Rule)
+	case alerting.StateResolved:
+		// Only resolve alerts whose firing was shown, replacing it
+		if _, ok := n.ids[key]; !ok {
+			n.mu.Unlock()
+			return
+		}
+		summary = fmt.Sprintf("Resolved: %s", alert.Rule)
+	default:
+		n.mu.Unlock()
+		return
+	}
+	replaces := n.ids[key]
+	n.mu.Unlock()
+
+	unit := n.config.Snapshot().TemperatureUnit
+	body := fmt.Sprintf("%s is %s (threshold %s %s)", alert.Path,
+		types.FormatValue(alert.Path, alert.Value, unit), alert.Comparison,
+		types.FormatValue(alert.Path, alert.Threshold, unit))
+
+	var actions []string
+	if alert.State == alerting.StateFiring {
+		actions = append(actions, actionAcknowledge, "Acknowledge", actionSnooze, "Snooze 1h")
+		if n.openHistory != nil {
+			actions = append(actions, actionHistory, "Open history")
+		}
+	}
+
+	urgency := urgencyOf(alert.Severity)
+	if alert.State == alerting.StateResolved {
+		urgency = 0
+	}
+	id, err := send(server, replaces, iconOf(alert), summary, body, actions, urgency)
+	if err != nil {
+		logs.Error("Failed to send notification: %v", err)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if alert.State == alerting.StateResolved {
+		delete(n.ids, key)
+		delete(n.keys, id)
+		delete(n.alerts, id)
+		return
+	}
+	delete(n.keys, replaces)
+	delete(n.alerts, replaces)
+	n.ids[key] = id
+	n.keys[id] = key
+	n.alerts[id] = alert
+}
+
+// notifyForecast shows the notification of a resource forecast to be full
+// within the horizon, unless it was notified within the cooldown
+func (n *Notifier) notifyForecast(server dbus.BusObject, event forecast.Event) {
+	f := event.Forecast
+	key := "forecast " + f.Resource
+	now := time.Now()
+
+	n.mu.Lock()
+	if last, ok := n.notified[key]; ok && now.Sub(last) < cooldown {
+		n.mu.Unlock()
+		return
+	}
+	n.notified[key] = now
+	replaces := n.ids[key]
+	n.mu.Unlock()
+
+	summary := fmt.Sprintf("%s full in %s", f.Name, forecast.FormatDuration(f.FullIn))
+	body := fmt.Sprintf("%s is %.1f%% used and growing by %s/h.",
+		f.Name, f.Used/f.Total*100, types.FormatBytes(f.Rate*3600))
+
+	var actions []string
+	if n.openHistory != nil {
+		actions = append(actions, actionHistory, "Open history")
+	}
+
+	id, err := send(server, replaces, assets.Path("error-icon.png"), summary, body, actions, 1)
+	if err != nil {
+		logs.Error("Failed to send notification: %v", err)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.keys, replaces)
+	n.ids[key] = id
+	n.keys[id] = key
+}
+
+// send calls Notify on the notification server, returning the notification id
+func send(server dbus.BusObject, replaces uint32, icon, summary, body string, actions []string, urgency byte) (uint32, error) {
+	hints := map[string]dbus.Variant{"urgency": dbus.MakeVariant(urgency)}
+
+	var id uint32
+	call := server.Call(notificationsInterface+".Notify", 0,
+		"p-monitor", replaces, icon, summary, body, actions, hints, int32(-1))
+	if err := call.Store(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// handleSignal runs notification actions and forgets closed notifications
+func (n *Notifier) handleSignal(signal *dbus.Signal) {
+	switch signal.Name {
+	case notificationsInterface + ".ActionInvoked":
+		var id uint32
+		var action string
+		if err := dbus.Store(signal.Body, &id, &action); err != nil {
+			return
+		}
+
+		n.mu.Lock()
+		_, ok := n.keys[id]
+		alert, isAlert := n.alerts[id]
+		n.mu.Unlock()
+		if !ok {
+			return
+		}
+
+		// The engine persists acknowledgements and snoozes, and logs them
+		switch action {
+		case actionAcknowledge:
+			if isAlert {
+				n.engine.Acknowledge(alert.Rule, alert.Path)
+			}
+		case actionSnooze:
+			if isAlert {
+				n.engine.Snooze(alert.Rule, alert.Path, alerting.SnoozeDuration)
+			}
+		case actionHistory:
+			if n.openHistory != nil {
+				n.openHistory()
+			}
+		}
+	case notificationsInterface + ".NotificationClosed":
+		var id, reason uint32
+		if err := dbus.Store(signal.Body, &id, &reason); err != nil {
+			return
+		}
+
+		n.mu.Lock()
+		if key, ok := n.keys[id]; ok {
+			delete(n.keys, id)
+			delete(n.alerts, id)
+			delete(n.ids, key)
+		}
+		n.mu.Unlock()
+	}
+}
+
+// alertKey identifies an alert across transitions
+func alertKey(alert alerting.Alert) string {
+	return alert.Rule + " " + alert.Path
+}
+
+// urgencyOf maps a severity to a notification urgency: 0 low, 1 normal, 2 critical
+func urgencyOf(severity string) byte {
+	switch severity {
+	case "critical":
+		return 2
+	case "warning":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// iconOf returns the absolute path of the icon of an alert
+func iconOf(alert alerting.Alert) string {
+	if alert.State == alerting.StateFiring && alert.Severity != "info" {
+		return assets.Path("error-icon.png")
+	}
+	return assets.Path("icon.png")
+}
+
+// title capitalizes a severity for display
+func title(severity string) string {
+	if severity == "" {
+		return severity
+	}
+	return strings.ToUpper(severity[:1]) + severity[1:]
+}
@@ -0,0 +1,57 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"p-monitor/pkg/types"
+)
+
+// FileWriter writes the latest metrics as JSON to a file. The file is replaced
+// atomically so readers never see a partially written snapshot.
+type FileWriter struct {
+	path string
+}
+
+// NewFileWriter creates a writer for the given path
+func NewFileWriter(path string) *FileWriter {
+	return &FileWriter{path: path}
+}
+
+// Write replaces the file content with metrics
+func (w *FileWriter) Write(metrics *types.SystemMetrics) error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(metrics, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metrics: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), filepath.Base(w.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary output file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write output file: %v", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set output file permissions: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), w.path); err != nil {
+		return fmt.Errorf("failed to replace output file: %v", err)
+	}
+
+	return nil
+}
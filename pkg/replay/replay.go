@@ -0,0 +1,99 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"p-monitor/pkg/types"
+)
+
+// Recorder writes every collection it is given to a recording file, one
+// SystemMetrics JSON object per line. It is safe for concurrent use.
+type Recorder struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Create creates a recording file, replacing any existing one
+func Create(path string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %v", err)
+	}
+	return &Recorder{file: file}, nil
+}
+
+// Write appends a collection to the recording
+func (r *Recorder) Write(metrics *types.SystemMetrics) error {
+	line, err := json.Marshal(metrics)
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write recording: %v", err)
+	}
+	return nil
+}
+
+// Close closes the recording file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// Player reads the collections of a recording file in order
+type Player struct {
+	file   *os.File
+	reader *bufio.Reader
+	line   int
+}
+
+// Open opens a recording file for playback
+func Open(path string) (*Player, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %v", err)
+	}
+	return &Player{file: file, reader: bufio.NewReader(file)}, nil
+}
+
+// Next returns the next collection of the recording, or io.EOF at its end.
+// Blank lines are skipped.
+func (p *Player) Next() (*types.SystemMetrics, error) {
+	for {
+		line, err := p.reader.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("failed to read recording: %v", err)
+		}
+		p.line++
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var metrics types.SystemMetrics
+		if err := json.Unmarshal(line, &metrics); err != nil {
+			return nil, fmt.Errorf("invalid sample on line %d: %v", p.line, err)
+		}
+		return &metrics, nil
+	}
+}
+
+// Close closes the recording file
+func (p *Player) Close() error {
+	return p.file.Close()
+}
@@ -0,0 +1,42 @@
+package smart
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// nvmeHealthLogID is the log page identifier of the SMART / health log
+	nvmeHealthLogID = 0x02
+
+	// nvmeHealthLogSize is the size of the SMART / health log page
+	nvmeHealthLogSize = 512
+
+	// kelvinOffset converts the Kelvin temperatures of NVMe logs to Celsius,
+	// rounded like smartctl does
+	kelvinOffset = 273
+)
+
+// parseNVMeHealthLog decodes a SMART / health log page, laid out as in the
+// NVMe base specification: critical warning at byte 0, composite temperature
+// in Kelvin at 1-2, available spare, its threshold and percentage used at
+// 3-5, and the media errors counter at 160-175
+func parseNVMeHealthLog(data []byte) (*nvmeHealth, error) {
+	if len(data) < nvmeHealthLogSize {
+		return nil, fmt.Errorf("NVMe health log is %d bytes, expected %d", len(data), nvmeHealthLogSize)
+	}
+
+	health := &nvmeHealth{
+		CriticalWarning:         int(data[0]),
+		AvailableSpare:          float64(data[3]),
+		AvailableSpareThreshold: float64(data[4]),
+		PercentageUsed:          float64(data[5]),
+		// The counter is 128 bits wide, the upper half is never reached
+		MediaErrors: binary.LittleEndian.Uint64(data[160:168]),
+	}
+	if kelvin := binary.LittleEndian.Uint16(data[1:3]); kelvin > 0 {
+		health.Temperature = float64(kelvin) - kelvinOffset
+	}
+
+	return health, nil
+}
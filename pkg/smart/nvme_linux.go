@@ -0,0 +1,70 @@
+package smart
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// nvmeIoctlAdminCmd is NVME_IOCTL_ADMIN_CMD, _IOWR('N', 0x41, struct nvme_admin_cmd)
+const nvmeIoctlAdminCmd = 0xc0484e41
+
+// nvmeAdminGetLogPage is the opcode of the Get Log Page admin command
+const nvmeAdminGetLogPage = 0x02
+
+// nvmeAdminCmd mirrors struct nvme_admin_cmd of linux/nvme_ioctl.h
+type nvmeAdminCmd struct {
+	opcode      uint8
+	flags       uint8
+	rsvd1       uint16
+	nsid        uint32
+	cdw2        uint32
+	cdw3        uint32
+	metadata    uint64
+	addr        uint64
+	metadataLen uint32
+	dataLen     uint32
+	cdw10       uint32
+	cdw11       uint32
+	cdw12       uint32
+	cdw13       uint32
+	cdw14       uint32
+	cdw15       uint32
+	timeoutMs   uint32
+	result      uint32 // Command specific dword 0 of the completion
+}
+
+// readNVMeHealthLog reads the SMART / health log of an NVMe controller
+// device, e.g. /dev/nvme0, through the admin command ioctl
+func readNVMeHealthLog(device string) (*nvmeHealth, error) {
+	file, err := os.Open(device)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data := make([]byte, nvmeHealthLogSize)
+	cmd := nvmeAdminCmd{
+		opcode:  nvmeAdminGetLogPage,
+		nsid:    0xffffffff, // The controller wide log
+		addr:    uint64(uintptr(unsafe.Pointer(&data[0]))),
+		dataLen: nvmeHealthLogSize,
+		// Number of dwords to read minus one, then the log page
+		cdw10: (nvmeHealthLogSize/4-1)<<16 | nvmeHealthLogID,
+	}
+
+	// The ioctl returns the NVMe completion status, non-zero when the
+	// controller rejected the command and left the buffer untouched
+	status, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), nvmeIoctlAdminCmd, uintptr(unsafe.Pointer(&cmd)))
+	runtime.KeepAlive(data)
+	if errno != 0 {
+		return nil, fmt.Errorf("failed to read NVMe health log of %s: %v", device, errno)
+	}
+	if status != 0 {
+		return nil, fmt.Errorf("failed to read NVMe health log of %s: status 0x%x", device, status)
+	}
+
+	return parseNVMeHealthLog(data)
+}
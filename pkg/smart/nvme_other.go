@@ -0,0 +1,11 @@
+//go:build !linux
+
+package smart
+
+import "errors"
+
+// readNVMeHealthLog reads the SMART / health log of an NVMe controller, which
+// is only supported on Linux
+func readNVMeHealthLog(device string) (*nvmeHealth, error) {
+	return nil, errors.New("NVMe health log is only read on Linux")
+}
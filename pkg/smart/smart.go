@@ -0,0 +1,397 @@
+package smart
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/command"
+	"p-monitor/pkg/types"
+)
+
+const (
+	// percentageUsedWarning is the NVMe endurance usage from which a warning is raised
+	percentageUsedWarning = 90
+
+	// smartctlFatalBits are the smartctl exit status bits meaning no data was read
+	// (bit 0: command line did not parse, bit 1: device open failed)
+	smartctlFatalBits = 0x03
+
+	// smartctlOpenFailedBit is the smartctl exit status bit set when the
+	// device could not be opened, e.g. without permission
+	smartctlOpenFailedBit = 0x02
+
+	// smartctlFailingBit is the smartctl exit status bit set when SMART status is failing
+	smartctlFailingBit = 0x08
+
+	// smartctlBackoff is how long smartctl isn't run again on a drive it
+	// failed to open or that made it time out
+	smartctlBackoff = 10 * time.Minute
+
+	// ATA SMART attributes counting failing sectors
+	ataReallocatedAttribute   = 5
+	ataPendingAttribute       = 197
+	ataUncorrectableAttribute = 198
+)
+
+// ataWarningAttributes maps ATA SMART attribute IDs that must stay at zero to their warning
+var ataWarningAttributes = map[int]string{
+	ataReallocatedAttribute:   "reallocated sectors",
+	ataPendingAttribute:       "pending sectors",
+	ataUncorrectableAttribute: "uncorrectable sectors",
+}
+
+// errCannotOpen is returned when smartctl could not open a drive
+var errCannotOpen = errors.New("smartctl cannot open the device")
+
+// errSmartctlMissing is returned for drives only smartctl could read when it
+// is not installed
+var errSmartctlMissing = errors.New("smartctl not installed")
+
+// drive is a physical drive found in sysfs
+type drive struct {
+	name      string // Kernel name, e.g. "nvme0" or "sda"
+	driveType string // "nvme" or "sata"
+	sysPath   string // Directory holding the model and hwmon entries
+}
+
+// smartctlOutput is the subset of `smartctl --json` output used by p-monitor
+type smartctlOutput struct {
+	Smartctl struct {
+		ExitStatus int `json:"exit_status"`
+		Messages   []struct {
+			String   string `json:"string"`
+			Severity string `json:"severity"`
+		} `json:"messages"`
+	} `json:"smartctl"`
+	ModelName   string `json:"model_name"`
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature struct {
+		Current float64 `json:"current"`
+	} `json:"temperature"`
+	NVMeHealth    *nvmeHealth `json:"nvme_smart_health_information_log"`
+	ATAAttributes *struct {
+		Table []struct {
+			ID  int `json:"id"`
+			Raw struct {
+				Value uint64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+}
+
+// nvmeHealth is the NVMe SMART / health information log, as printed by
+// smartctl or read from the controller
+type nvmeHealth struct {
+	CriticalWarning         int     `json:"critical_warning"`
+	Temperature             float64 `json:"temperature"` // Celsius
+	AvailableSpare          float64 `json:"available_spare"`
+	AvailableSpareThreshold float64 `json:"available_spare_threshold"`
+	PercentageUsed          float64 `json:"percentage_used"`
+	MediaErrors             uint64  `json:"media_errors"`
+}
+
+// backoff is a drive smartctl failed to open, not retried until a given time
+type backoff struct {
+	until time.Time
+	err   error
+}
+
+// Collector collects drive health through smartctl, falling back to the NVMe
+// health log and to sysfs. Drives smartctl can't open or times out on are only
+// retried after smartctlBackoff. It is not safe for concurrent use.
+type Collector struct {
+	runner  command.Runner
+	sysRoot string                                   // Mount point of sysfs
+	devRoot string                                   // Directory holding the device nodes
+	readLog func(device string) (*nvmeHealth, error) // Reads the health log of an NVMe controller
+	backoff map[string]backoff                       // Keyed by drive name
+}
+
+// NewCollector creates a drive health collector finding drives under the
+// given sysfs root, opening them under devRoot and running smartctl through
+// runner
+func NewCollector(runner command.Runner, sysRoot, devRoot string) *Collector {
+	return &Collector{
+		runner:  runner,
+		sysRoot: sysRoot,
+		devRoot: devRoot,
+		readLog: readNVMeHealthLog,
+		backoff: make(map[string]backoff),
+	}
+}
+
+// CollectAll collects health information from all NVMe and SATA drives. The
+// error joins the drives that failed to be read, leaving out those this system
+// can't read, without smartctl or permission to open them, whose entry only
+// carries the reason.
+func (c *Collector) CollectAll() ([]*types.DriveHealth, error) {
+	var health []*types.DriveHealth
+
+	drives := findDrives(c.sysRoot)
+	if len(drives) == 0 {
+		return health, nil
+	}
+
+	_, err := c.runner.LookPath("smartctl")
+	hasSmartctl := err == nil
+	if !hasSmartctl {
+		logs.Debug("smartctl not found, drive health limited to the NVMe health log and sysfs data")
+	}
+
+	var failures []string
+	for _, d := range drives {
+		drive, err := c.collectDriveHealth(d, hasSmartctl)
+		health = append(health, drive)
+		if err != nil {
+			failures = append(failures, d.name+": "+err.Error())
+		}
+	}
+
+	if len(failures) > 0 {
+		return health, errors.New(strings.Join(failures, "; "))
+	}
+	return health, nil
+}
+
+// collectDriveHealth collects health information of a single drive, preferring
+// smartctl and falling back to the NVMe health log and sysfs when it is
+// missing or can't open the device. The error is only returned when reading
+// failed, not when the drive is unsupported.
+func (c *Collector) collectDriveHealth(d drive, hasSmartctl bool) (*types.DriveHealth, error) {
+	health := &types.DriveHealth{
+		Device: d.name,
+		Model:  readSysfsString(filepath.Join(d.sysPath, "model")),
+		Type:   d.driveType,
+	}
+	device := filepath.Join(c.devRoot, d.name)
+
+	var err error
+	if b, ok := c.backoff[d.name]; ok && time.Now().Before(b.until) {
+		err = b.err
+	} else if hasSmartctl {
+		err = c.readSmartctl(device, health)
+		if err == nil {
+			delete(c.backoff, d.name)
+			health.Warnings = healthWarnings(health)
+			return health, nil
+		}
+		logs.Debug("Failed to read SMART data of %s: %v", d.name, err)
+
+		// Opening fails the same way until permissions change, and a hung
+		// drive would delay every collection, so don't run smartctl on
+		// every collection
+		if errors.Is(err, errCannotOpen) || errors.Is(err, command.ErrTimeout) {
+			c.backoff[d.name] = backoff{until: time.Now().Add(smartctlBackoff), err: err}
+		}
+	} else {
+		err = errSmartctlMissing
+	}
+
+	failure := err
+	if unsupported(err) {
+		failure = nil
+	}
+
+	// NVMe controllers report their health log without smartctl
+	if d.driveType == "nvme" {
+		nvme, logErr := c.readLog(device)
+		if logErr == nil {
+			applyNVMeHealth(health, nvme)
+			passed := nvme.CriticalWarning == 0
+			health.Passed = &passed
+			health.Warnings = healthWarnings(health)
+			return health, nil
+		}
+		logs.Debug("Failed to read NVMe health log of %s: %v", d.name, logErr)
+		if failure == nil && !unsupported(logErr) {
+			failure = logErr
+		}
+	}
+	health.Error = err.Error()
+
+	// Only the temperature is exposed through sysfs
+	if temp, err := readHwmonTemperature(d.sysPath); err == nil {
+		health.Temperature = temp
+	}
+
+	return health, failure
+}
+
+// unsupported reports whether err means that this system can't read a drive,
+// without smartctl or permission to open it, rather than that reading failed
+func unsupported(err error) bool {
+	return errors.Is(err, errSmartctlMissing) || errors.Is(err, errCannotOpen) ||
+		errors.Is(err, os.ErrPermission) || errors.Is(err, os.ErrNotExist)
+}
+
+// readSmartctl fills health from `smartctl --json --all` output of a device
+func (c *Collector) readSmartctl(device string, health *types.DriveHealth) error {
+	result, err := c.runner.Run("smartctl", "--json", "--all", device)
+	if err != nil {
+		return err
+	}
+
+	// smartctl reports drive problems through its exit status, while still
+	// printing valid output, so only give up when nothing was printed
+	if strings.TrimSpace(result.Stdout) == "" {
+		return fmt.Errorf("smartctl returned no output: %v", result.Err())
+	}
+
+	var parsed smartctlOutput
+	if err := json.Unmarshal([]byte(result.Stdout), &parsed); err != nil {
+		return fmt.Errorf("failed to parse smartctl output: %v", err)
+	}
+
+	if parsed.Smartctl.ExitStatus&smartctlOpenFailedBit != 0 {
+		return fmt.Errorf("%w: %s", errCannotOpen, smartctlMessage(&parsed))
+	}
+	if parsed.Smartctl.ExitStatus&smartctlFatalBits != 0 {
+		return fmt.Errorf("smartctl failed: %s", smartctlMessage(&parsed))
+	}
+
+	if parsed.ModelName != "" {
+		health.Model = parsed.ModelName
+	}
+	health.Temperature = parsed.Temperature.Current
+
+	// The self-assessment stays unknown unless smartctl reported it
+	if parsed.SmartStatus != nil || parsed.Smartctl.ExitStatus&smartctlFailingBit != 0 {
+		passed := parsed.Smartctl.ExitStatus&smartctlFailingBit == 0
+		if parsed.SmartStatus != nil {
+			passed = passed && parsed.SmartStatus.Passed
+		}
+		health.Passed = &passed
+	}
+
+	if nvme := parsed.NVMeHealth; nvme != nil {
+		applyNVMeHealth(health, nvme)
+	}
+
+	if ata := parsed.ATAAttributes; ata != nil {
+		for _, attribute := range ata.Table {
+			warning, ok := ataWarningAttributes[attribute.ID]
+			if !ok || attribute.Raw.Value == 0 {
+				continue
+			}
+			if attribute.ID == ataUncorrectableAttribute {
+				health.MediaErrors = attribute.Raw.Value
+			}
+			health.Warnings = append(health.Warnings, fmt.Sprintf("%d %s", attribute.Raw.Value, warning))
+		}
+	}
+
+	return nil
+}
+
+// applyNVMeHealth fills health from an NVMe health log
+func applyNVMeHealth(health *types.DriveHealth, nvme *nvmeHealth) {
+	if nvme.Temperature > 0 {
+		health.Temperature = nvme.Temperature
+	}
+	health.PercentageUsed = nvme.PercentageUsed
+	health.AvailableSpare = nvme.AvailableSpare
+	health.MediaErrors = nvme.MediaErrors
+
+	if nvme.CriticalWarning != 0 {
+		health.Warnings = append(health.Warnings, fmt.Sprintf("critical warning 0x%02x", nvme.CriticalWarning))
+	}
+	if nvme.AvailableSpareThreshold > 0 && nvme.AvailableSpare < nvme.AvailableSpareThreshold {
+		health.Warnings = append(health.Warnings, fmt.Sprintf("available spare %.0f%% below threshold", nvme.AvailableSpare))
+	}
+}
+
+// healthWarnings appends the warnings derived from the collected values
+func healthWarnings(health *types.DriveHealth) []string {
+	warnings := health.Warnings
+
+	if health.Passed != nil && !*health.Passed {
+		warnings = append(warnings, "SMART self-assessment failed")
+	}
+	if health.PercentageUsed >= percentageUsedWarning {
+		warnings = append(warnings, fmt.Sprintf("%.0f%% of endurance used", health.PercentageUsed))
+	}
+	if health.Type == "nvme" && health.MediaErrors > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d media errors", health.MediaErrors))
+	}
+
+	return warnings
+}
+
+// smartctlMessage joins the messages smartctl printed
+func smartctlMessage(parsed *smartctlOutput) string {
+	var messages []string
+	for _, message := range parsed.Smartctl.Messages {
+		messages = append(messages, message.String)
+	}
+
+	if len(messages) == 0 {
+		return fmt.Sprintf("exit status %d", parsed.Smartctl.ExitStatus)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// findDrives lists NVMe controllers and non removable SATA disks from sysfs
+func findDrives(sysRoot string) []drive {
+	var drives []drive
+
+	nvmeControllers, _ := filepath.Glob(filepath.Join(sysRoot, "class", "nvme", "nvme*"))
+	for _, path := range nvmeControllers {
+		drives = append(drives, drive{
+			name:      filepath.Base(path),
+			driveType: "nvme",
+			sysPath:   path,
+		})
+	}
+
+	sataDisks, _ := filepath.Glob(filepath.Join(sysRoot, "block", "sd*"))
+	for _, path := range sataDisks {
+		if readSysfsString(filepath.Join(path, "removable")) == "1" {
+			continue
+		}
+		drives = append(drives, drive{
+			name:      filepath.Base(path),
+			driveType: "sata",
+			sysPath:   filepath.Join(path, "device"),
+		})
+	}
+
+	return drives
+}
+
+// readHwmonTemperature reads the composite temperature a drive exposes through hwmon.
+// NVMe controllers expose hwmon directly while SATA disks need the drivetemp module.
+func readHwmonTemperature(sysPath string) (float64, error) {
+	matches, _ := filepath.Glob(filepath.Join(sysPath, "hwmon*", "temp1_input"))
+	if len(matches) == 0 {
+		matches, _ = filepath.Glob(filepath.Join(sysPath, "hwmon", "hwmon*", "temp1_input"))
+	}
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("no hwmon temperature found")
+	}
+
+	value, err := strconv.ParseFloat(readSysfsString(matches[0]), 64)
+	if err != nil {
+		return 0, err
+	}
+
+	// Temperature is in millidegrees Celsius
+	return value / 1000.0, nil
+}
+
+// readSysfsString reads a sysfs attribute, returning an empty string on failure
+func readSysfsString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,250 @@
+package smart
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"p-monitor/pkg/command"
+	"p-monitor/pkg/types"
+)
+
+// fakeRunner replays smartctl outputs recorded under testdata/smartctl,
+// keyed by device path, and counts the runs
+type fakeRunner struct {
+	installed bool
+	outputs   map[string]string // Recorded output file by device path
+	runs      int
+}
+
+func (f *fakeRunner) LookPath(name string) (string, error) {
+	if !f.installed {
+		return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+	return name, nil
+}
+
+func (f *fakeRunner) Run(name string, args ...string) (*command.Result, error) {
+	f.runs++
+
+	device := args[len(args)-1]
+	file, ok := f.outputs[device]
+	if !ok {
+		return nil, &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", "smartctl", file))
+	if err != nil {
+		return nil, err
+	}
+	return &command.Result{Stdout: string(data)}, nil
+}
+
+// newTestCollector creates a collector reading the recorded sysfs tree, with
+// device nodes under /host/dev and NVMe health logs from readLog
+func newTestCollector(runner *fakeRunner, readLog func(string) (*nvmeHealth, error)) *Collector {
+	c := NewCollector(runner, filepath.Join("testdata", "sys"), "/host/dev")
+	c.readLog = readLog
+	return c
+}
+
+// noHealthLog fails like an NVMe controller opened without permission
+func noHealthLog(device string) (*nvmeHealth, error) {
+	return nil, &os.PathError{Op: "open", Path: device, Err: os.ErrPermission}
+}
+
+func boolPointer(value bool) *bool {
+	return &value
+}
+
+func TestCollectAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		runner  *fakeRunner
+		readLog func(string) (*nvmeHealth, error)
+		want    []*types.DriveHealth
+		wantErr string
+	}{
+		{
+			name: "smartctl",
+			runner: &fakeRunner{installed: true, outputs: map[string]string{
+				"/host/dev/nvme0": "nvme0.json",
+				"/host/dev/sda":   "sda.json",
+			}},
+			readLog: noHealthLog,
+			want: []*types.DriveHealth{
+				{Device: "nvme0", Model: "Samsung SSD 980 PRO 1TB", Type: "nvme", Temperature: 39, PercentageUsed: 3, AvailableSpare: 100, Passed: boolPointer(true)},
+				{Device: "sda", Model: "ST2000DM008-2FR102", Type: "sata", Temperature: 34, MediaErrors: 2, Passed: boolPointer(true),
+					Warnings: []string{"8 reallocated sectors", "2 uncorrectable sectors"}},
+			},
+		},
+		{
+			name: "self-assessment not reported",
+			runner: &fakeRunner{installed: true, outputs: map[string]string{
+				"/host/dev/nvme0": "nvme0.json",
+				"/host/dev/sda":   "sda_no_status.json",
+			}},
+			readLog: noHealthLog,
+			want: []*types.DriveHealth{
+				{Device: "nvme0", Model: "Samsung SSD 980 PRO 1TB", Type: "nvme", Temperature: 39, PercentageUsed: 3, AvailableSpare: 100, Passed: boolPointer(true)},
+				{Device: "sda", Model: "ST2000DM008-2FR102", Type: "sata", Temperature: 31},
+			},
+		},
+		{
+			name:   "without smartctl",
+			runner: &fakeRunner{},
+			readLog: func(device string) (*nvmeHealth, error) {
+				if device != "/host/dev/nvme0" {
+					t.Errorf("health log read from %s, want /host/dev/nvme0", device)
+				}
+				return &nvmeHealth{CriticalWarning: 0x04, Temperature: 41, AvailableSpare: 5, AvailableSpareThreshold: 10, PercentageUsed: 97, MediaErrors: 3}, nil
+			},
+			want: []*types.DriveHealth{
+				{Device: "nvme0", Model: "Samsung SSD 980 PRO 1TB", Type: "nvme", Temperature: 41, PercentageUsed: 97, AvailableSpare: 5, MediaErrors: 3, Passed: boolPointer(false),
+					Warnings: []string{"critical warning 0x04", "available spare 5% below threshold", "SMART self-assessment failed", "97% of endurance used", "3 media errors"}},
+				{Device: "sda", Model: "ST2000DM008-2FR1", Type: "sata", Error: "smartctl not installed"},
+			},
+		},
+		{
+			name:    "without smartctl nor health log",
+			runner:  &fakeRunner{},
+			readLog: noHealthLog,
+			want: []*types.DriveHealth{
+				// Only the hwmon temperature is left
+				{Device: "nvme0", Model: "Samsung SSD 980 PRO 1TB", Type: "nvme", Temperature: 38.85, Error: "smartctl not installed"},
+				{Device: "sda", Model: "ST2000DM008-2FR1", Type: "sata", Error: "smartctl not installed"},
+			},
+		},
+		{
+			name:   "health log read failure",
+			runner: &fakeRunner{},
+			readLog: func(device string) (*nvmeHealth, error) {
+				return nil, errors.New("failed to read NVMe health log of " + device + ": status 0x2")
+			},
+			want: []*types.DriveHealth{
+				{Device: "nvme0", Model: "Samsung SSD 980 PRO 1TB", Type: "nvme", Temperature: 38.85, Error: "smartctl not installed"},
+				{Device: "sda", Model: "ST2000DM008-2FR1", Type: "sata", Error: "smartctl not installed"},
+			},
+			wantErr: "nvme0: failed to read NVMe health log of /host/dev/nvme0: status 0x2",
+		},
+		{
+			name:    "smartctl failure",
+			runner:  &fakeRunner{installed: true, outputs: map[string]string{"/host/dev/nvme0": "nvme0.json"}},
+			readLog: noHealthLog,
+			want: []*types.DriveHealth{
+				{Device: "nvme0", Model: "Samsung SSD 980 PRO 1TB", Type: "nvme", Temperature: 39, PercentageUsed: 3, AvailableSpare: 100, Passed: boolPointer(true)},
+				{Device: "sda", Model: "ST2000DM008-2FR1", Type: "sata", Error: "exec: \"smartctl\": executable file not found in $PATH"},
+			},
+			wantErr: "sda: exec: \"smartctl\": executable file not found in $PATH",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newTestCollector(tt.runner, tt.readLog).CollectAll()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CollectAll() =\n%s\nwant\n%s", describe(got), describe(tt.want))
+			}
+			if gotErr := fmt.Sprint(err); (err != nil || tt.wantErr != "") && gotErr != tt.wantErr {
+				t.Errorf("CollectAll() error = %s, want %q", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCollectAllBacksOff(t *testing.T) {
+	runner := &fakeRunner{installed: true, outputs: map[string]string{
+		"/host/dev/nvme0": "nvme0.json",
+		"/host/dev/sda":   "sda_permission.json",
+	}}
+	c := newTestCollector(runner, noHealthLog)
+
+	for i := 0; i < 3; i++ {
+		drives, err := c.CollectAll()
+		if err != nil {
+			t.Errorf("collection %d: a drive without permission failed the collection: %v", i, err)
+		}
+		if len(drives) != 2 {
+			t.Fatalf("CollectAll() returned %d drives, want 2", len(drives))
+		}
+		if drives[1].Error != "smartctl cannot open the device: Smartctl open device: /dev/sda failed: Permission denied" {
+			t.Errorf("collection %d: sda error = %q", i, drives[1].Error)
+		}
+		if drives[1].Passed != nil {
+			t.Errorf("collection %d: sda self-assessment = %v, want unknown", i, *drives[1].Passed)
+		}
+	}
+
+	// nvme0 on every collection, sda only on the first
+	if runner.runs != 4 {
+		t.Errorf("smartctl ran %d times, want 4", runner.runs)
+	}
+
+	// Once the backoff elapsed smartctl is run again
+	b := c.backoff["sda"]
+	b.until = b.until.Add(-smartctlBackoff)
+	c.backoff["sda"] = b
+	runner.outputs["/host/dev/sda"] = "sda.json"
+	if drives, _ := c.CollectAll(); drives[1].Error != "" {
+		t.Errorf("sda error after the backoff = %q, want none", drives[1].Error)
+	}
+	if _, ok := c.backoff["sda"]; ok {
+		t.Error("sda is still backed off after a successful run")
+	}
+}
+
+func TestParseNVMeHealthLog(t *testing.T) {
+	data := make([]byte, nvmeHealthLogSize)
+	data[0] = 0x01                                // Available spare below threshold
+	binary.LittleEndian.PutUint16(data[1:3], 318) // 45°C
+	data[3] = 8
+	data[4] = 10
+	data[5] = 12
+	binary.LittleEndian.PutUint64(data[160:168], 7)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    *nvmeHealth
+		wantErr bool
+	}{
+		{
+			name: "health log",
+			data: data,
+			want: &nvmeHealth{CriticalWarning: 1, Temperature: 45, AvailableSpare: 8, AvailableSpareThreshold: 10, PercentageUsed: 12, MediaErrors: 7},
+		},
+		{
+			name: "no temperature sensor",
+			data: make([]byte, nvmeHealthLogSize),
+			want: &nvmeHealth{},
+		},
+		{
+			name:    "truncated",
+			data:    data[:256],
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseNVMeHealthLog(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNVMeHealthLog() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseNVMeHealthLog() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// describe formats drives for test failures
+func describe(drives []*types.DriveHealth) string {
+	data, _ := json.MarshalIndent(drives, "", "  ")
+	return string(data)
+}
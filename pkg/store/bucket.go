@@ -0,0 +1,69 @@
+package store
+
+import (
+	"maps"
+	"time"
+)
+
+// bucket aggregates the records of one downsampling interval
+type bucket struct {
+	start  time.Time
+	count  int // Number of raw samples aggregated
+	sums   map[string]float64
+	counts map[string]int
+	mins   map[string]float64
+	maxs   map[string]float64
+}
+
+// newBucket creates an empty bucket starting at start
+func newBucket(start time.Time) *bucket {
+	return &bucket{
+		start:  start,
+		sums:   make(map[string]float64),
+		counts: make(map[string]int),
+		mins:   make(map[string]float64),
+		maxs:   make(map[string]float64),
+	}
+}
+
+// add aggregates a record, weighting downsampled records by the number of
+// raw samples they stand for
+func (b *bucket) add(record Record) {
+	weight := max(record.Count, 1)
+	b.count += weight
+
+	for path, value := range record.Values {
+		low, high := value, value
+		if v, ok := record.Min[path]; ok {
+			low = v
+		}
+		if v, ok := record.Max[path]; ok {
+			high = v
+		}
+
+		if b.counts[path] == 0 {
+			b.mins[path], b.maxs[path] = low, high
+		} else {
+			b.mins[path] = min(b.mins[path], low)
+			b.maxs[path] = max(b.maxs[path], high)
+		}
+		b.sums[path] += value * float64(weight)
+		b.counts[path] += weight
+	}
+}
+
+// record returns the aggregate of the bucket, averaging every path. The
+// record shares no map with the bucket, which keeps accumulating.
+func (b *bucket) record() Record {
+	record := Record{
+		Time:   b.start,
+		Values: make(map[string]float64, len(b.sums)),
+		Min:    maps.Clone(b.mins),
+		Max:    maps.Clone(b.maxs),
+		Count:  b.count,
+	}
+	for path, sum := range b.sums {
+		record.Values[path] = sum / float64(b.counts[path])
+	}
+	return record
+}
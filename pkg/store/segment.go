@@ -0,0 +1,163 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// segmentSuffix is the file extension of segment files
+	segmentSuffix = ".log"
+
+	// segmentTimeFormat names segment files after the UTC start of their period
+	segmentTimeFormat = "20060102T150405Z"
+
+	// maxLineSize bounds the length of a single record line
+	maxLineSize = 16 * 1024 * 1024
+)
+
+// segment is a file of records covering one period of a tier. Every record is
+// a line holding the CRC-32 of its JSON followed by the JSON itself, so lines
+// torn or damaged by a crash are detected and skipped.
+type segment struct {
+	path  string
+	start time.Time
+	size  int64
+}
+
+// segmentName returns the file name of the segment starting at start
+func segmentName(start time.Time) string {
+	return start.UTC().Format(segmentTimeFormat) + segmentSuffix
+}
+
+// listSegments returns the segments of a tier directory, oldest first
+func listSegments(dir string) ([]segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var segments []segment
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		start, err := time.Parse(segmentTimeFormat, strings.TrimSuffix(name, segmentSuffix))
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{path: filepath.Join(dir, name), start: start, size: info.Size()})
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].start.Before(segments[j].start) })
+	return segments, nil
+}
+
+// readSegment returns the valid records of a segment file in file order
+func readSegment(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		if record, ok := decodeRecord(scanner.Bytes()); ok {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// encodeRecord returns the line of a record, including the trailing newline
+func encodeRecord(record Record) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Appendf(nil, "%08x %s\n", crc32.ChecksumIEEE(data), data), nil
+}
+
+// decodeRecord parses a record line, reporting false for damaged lines
+func decodeRecord(line []byte) (Record, bool) {
+	var record Record
+
+	checksum, data, found := bytes.Cut(line, []byte(" "))
+	if !found || string(checksum) != fmt.Sprintf("%08x", crc32.ChecksumIEEE(data)) {
+		return record, false
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, false
+	}
+	return record, true
+}
+
+// openSegment opens a segment file for appending. A partial last line left by
+// a crash is cut off first, so that new records start on a line of their own.
+func openSegment(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := completeLength(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// completeLength returns the length of a file up to and including its last newline
+func completeLength(file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// Scan backwards in blocks for the last newline
+	const blockSize = 4096
+	block := make([]byte, blockSize)
+	end := info.Size()
+	for end > 0 {
+		start := max(end-blockSize, 0)
+		n, err := file.ReadAt(block[:end-start], start)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(block[:n], '\n'); i >= 0 {
+			return start + int64(i) + 1, nil
+		}
+		end = start
+	}
+	return 0, nil
+}
@@ -0,0 +1,408 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"p-monitor/internal/logs"
+	"p-monitor/pkg/history"
+	"p-monitor/pkg/types"
+)
+
+// Record is a sample as stored on disk. Downsampled records hold the average
+// of every path in Values, the extremes in Min and Max and the number of raw
+// samples they aggregate in Count.
+type Record struct {
+	Time   time.Time          `json:"time"`
+	Values map[string]float64 `json:"values"`
+	Min    map[string]float64 `json:"min,omitempty"`
+	Max    map[string]float64 `json:"max,omitempty"`
+	Count  int                `json:"count,omitempty"`
+}
+
+// detailPrefixes are the metric paths only kept in the raw tier, as their
+// number grows with the cores, services and containers of the machine
+var detailPrefixes = []string{"cpu.cores[", "services[", "slices[", "containers["}
+
+// tier holds the records of one resolution in a directory of segment files
+type tier struct {
+	name       string
+	resolution time.Duration // Zero for raw samples
+	retention  time.Duration
+	period     time.Duration // Time covered by one segment file
+	dir        string
+	file       *os.File
+	fileStart  time.Time
+	pending    *bucket // Downsampling interval in progress
+	aggregates bool    // Whether only the paths outside detailPrefixes are kept
+}
+
+// Store is an append-only time-series store. Every sample is kept raw for a
+// day, as 1-minute aggregates for 30 days and as 1-hour aggregates for a year,
+// within an overall size cap. The aggregates leave out the per-core, service
+// and container paths. It is safe for concurrent use.
+type Store struct {
+	mu       sync.Mutex
+	maxBytes int64
+	tiers    []*tier
+}
+
+// Open opens or creates the store in dir, holding at most maxBytes of
+// segment files. A maxBytes of zero disables the size cap.
+func Open(dir string, maxBytes int64) (*Store, error) {
+	s := newStore(dir, maxBytes)
+	for _, t := range s.tiers {
+		if err := os.MkdirAll(t.dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create data directory: %v", err)
+		}
+	}
+
+	if err := s.recover(); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to recover data: %v", err)
+	}
+	s.enforceLimits()
+
+	return s, nil
+}
+
+// ReadRange returns the records within [from, to] like Range, without opening
+// the store for writing. It is meant for reading the store of another process;
+// the aggregate of the interval in progress is not included.
+func ReadRange(dir string, from, to time.Time) ([]Record, error) {
+	return newStore(dir, 0).tierFor(from).read(from, to)
+}
+
+// Samples converts records to history samples, keeping the average values
+func Samples(records []Record) []history.Sample {
+	samples := make([]history.Sample, 0, len(records))
+	for _, record := range records {
+		samples = append(samples, history.Sample{Time: record.Time, Values: record.Values})
+	}
+	return samples
+}
+
+// newStore creates a store of the tiers in dir, without touching the disk
+func newStore(dir string, maxBytes int64) *Store {
+	s := &Store{
+		maxBytes: maxBytes,
+		tiers: []*tier{
+			{name: "raw", retention: 24 * time.Hour, period: time.Hour},
+			{name: "1m", resolution: time.Minute, retention: 30 * 24 * time.Hour, period: 24 * time.Hour, aggregates: true},
+			{name: "1h", resolution: time.Hour, retention: 365 * 24 * time.Hour, period: 7 * 24 * time.Hour, aggregates: true},
+		},
+	}
+	for _, t := range s.tiers {
+		t.dir = filepath.Join(dir, t.name)
+	}
+	return s
+}
+
+// Append stores a collection and updates the downsampled tiers
+func (s *Store) Append(metrics *types.SystemMetrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := Record{Time: metrics.Updated, Values: metrics.Values()}
+	if err := s.write(s.tiers[0], record); err != nil {
+		return err
+	}
+	return s.feed(1, record, true)
+}
+
+// Range returns the records within [from, to], oldest first, from the finest
+// tier still covering from. A zero from reads the coarsest tier and a zero to
+// leaves the range open. The aggregate of the interval in progress is included.
+func (s *Store) Range(from, to time.Time) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.tierFor(from)
+	records, err := t.read(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.pending != nil && inRange(t.pending.start, from, to) {
+		records = append(records, t.pending.record())
+	}
+	return records, nil
+}
+
+// Query returns the values of a metric path within [from, to], see Range
+func (s *Store) Query(path string, from, to time.Time) ([]history.Point, error) {
+	records, err := s.Range(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var points []history.Point
+	for _, record := range records {
+		if value, ok := record.Values[path]; ok {
+			points = append(points, history.Point{Time: record.Time, Value: value})
+		}
+	}
+	return points, nil
+}
+
+// Close closes the open segment files. The interval in progress is rebuilt
+// from the finer tier on the next Open.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for _, t := range s.tiers {
+		if t.file == nil {
+			continue
+		}
+		if err := t.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		t.file = nil
+	}
+	return firstErr
+}
+
+// recover rebuilds the intervals in progress of the downsampled tiers by
+// replaying the finer tier past their last record, which also writes any
+// aggregates lost in a crash
+func (s *Store) recover() error {
+	for i := 1; i < len(s.tiers); i++ {
+		t := s.tiers[i]
+
+		last, err := t.lastRecordTime()
+		if err != nil {
+			return err
+		}
+		from := time.Time{}
+		if !last.IsZero() {
+			from = last.Add(t.resolution)
+		}
+
+		records, err := s.tiers[i-1].read(from, time.Time{})
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			// Coarser tiers are recovered on their own, so don't cascade
+			if err := s.feed(i, record, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// feed adds a record of the finer tier to the interval in progress of tier i,
+// writing the aggregate once the record starts a new interval
+func (s *Store) feed(i int, record Record, cascade bool) error {
+	if i >= len(s.tiers) {
+		return nil
+	}
+	t := s.tiers[i]
+
+	start := record.Time.Truncate(t.resolution)
+	if t.pending != nil && start.After(t.pending.start) {
+		aggregate := t.pending.record()
+		t.pending = nil
+
+		if err := s.write(t, aggregate); err != nil {
+			return err
+		}
+		if cascade {
+			if err := s.feed(i+1, aggregate, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	if t.pending == nil {
+		t.pending = newBucket(start)
+	}
+	if t.aggregates {
+		record = withoutDetails(record)
+	}
+	t.pending.add(record)
+	return nil
+}
+
+// withoutDetails returns a record without the paths matching detailPrefixes
+func withoutDetails(record Record) Record {
+	trimmed := record
+	trimmed.Values = make(map[string]float64, len(record.Values))
+	for path, value := range record.Values {
+		if !isDetail(path) {
+			trimmed.Values[path] = value
+		}
+	}
+	return trimmed
+}
+
+// isDetail reports whether a metric path matches detailPrefixes
+func isDetail(path string) bool {
+	for _, prefix := range detailPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// write appends a record to the segment of its period, syncing it to disk
+func (s *Store) write(t *tier, record Record) error {
+	start := record.Time.UTC().Truncate(t.period)
+
+	// Records from a clock set back stay in the current segment
+	if t.file == nil || start.After(t.fileStart) {
+		if t.file != nil {
+			t.file.Close()
+			t.file = nil
+		}
+
+		file, err := openSegment(filepath.Join(t.dir, segmentName(start)))
+		if err != nil {
+			return fmt.Errorf("failed to open segment: %v", err)
+		}
+		t.file, t.fileStart = file, start
+
+		s.enforceLimits()
+	}
+
+	line, err := encodeRecord(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %v", err)
+	}
+	if _, err := t.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write record: %v", err)
+	}
+	if err := t.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync segment: %v", err)
+	}
+	return nil
+}
+
+// enforceLimits removes segments past their tier's retention, then the oldest
+// segments of the raw tier, and only then of the coarser tiers, until the
+// store fits in maxBytes. Open segments are kept.
+func (s *Store) enforceLimits() {
+	now := time.Now()
+
+	candidates := make(map[*tier][]segment)
+	var total int64
+
+	for _, t := range s.tiers {
+		segments, err := listSegments(t.dir)
+		if err != nil {
+			logs.Error("Failed to list %s segments: %v", t.name, err)
+			continue
+		}
+
+		for _, seg := range segments {
+			open := t.file != nil && seg.start.Equal(t.fileStart)
+			if !open && seg.start.Add(t.period).Before(now.Add(-t.retention)) {
+				if err := os.Remove(seg.path); err != nil {
+					logs.Error("Failed to remove expired segment: %v", err)
+				}
+				continue
+			}
+
+			total += seg.size
+			if !open {
+				candidates[t] = append(candidates[t], seg)
+			}
+		}
+	}
+
+	if s.maxBytes <= 0 || total <= s.maxBytes {
+		return
+	}
+
+	// The coarser tiers cover the history of the finer ones for longer, so
+	// they are only evicted once the finer ones are exhausted. Segments are
+	// listed oldest first.
+	for _, t := range s.tiers {
+		for _, seg := range candidates[t] {
+			if total <= s.maxBytes {
+				return
+			}
+			if err := os.Remove(seg.path); err != nil {
+				logs.Error("Failed to remove segment: %v", err)
+				continue
+			}
+			total -= seg.size
+			logs.Info("Removed %s segment %s to stay within the data size limit", t.name, filepath.Base(seg.path))
+		}
+	}
+}
+
+// tierFor returns the finest tier whose retention still covers from
+func (s *Store) tierFor(from time.Time) *tier {
+	if !from.IsZero() {
+		age := time.Since(from)
+		for _, t := range s.tiers {
+			if age <= t.retention {
+				return t
+			}
+		}
+	}
+	return s.tiers[len(s.tiers)-1]
+}
+
+// read returns the records of the tier within [from, to], oldest first
+func (t *tier) read(from, to time.Time) ([]Record, error) {
+	segments, err := listSegments(t.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for _, seg := range segments {
+		if !from.IsZero() && !seg.start.Add(t.period).After(from) {
+			continue
+		}
+		if !to.IsZero() && seg.start.After(to) {
+			continue
+		}
+
+		segmentRecords, err := readSegment(seg.path)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range segmentRecords {
+			if inRange(record.Time, from, to) {
+				records = append(records, record)
+			}
+		}
+	}
+	return records, nil
+}
+
+// lastRecordTime returns the time of the newest record of the tier, or zero
+func (t *tier) lastRecordTime() (time.Time, error) {
+	segments, err := listSegments(t.dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// The newest segment may hold no valid record after a crash
+	for i := len(segments) - 1; i >= 0; i-- {
+		records, err := readSegment(segments[i].path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if len(records) > 0 {
+			return records[len(records)-1].Time, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// inRange reports whether t is within [from, to], zero bounds being open
+func inRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || !t.After(to))
+}
@@ -0,0 +1,200 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"p-monitor/pkg/types"
+)
+
+const (
+	// labelWidth is the width of the section label column
+	labelWidth = 16
+
+	// barWidth is the width of the usage bar of collapsed sections
+	barWidth = 20
+
+	// sparkLevels are the characters used to draw sparklines, from low to high
+	sparkLevels = "▁▂▃▄▅▆▇█"
+)
+
+// section is one dashboard entry, such as the CPU or a single GPU
+type section struct {
+	id      string
+	label   string
+	path    string   // Metric path of the sparkline values in the history
+	percent float64  // Value drawn in the bar
+	summary string   // Text shown after the bar
+	details []string // Extra lines shown when the section is selected
+}
+
+// buildSections converts metrics into dashboard sections
+func buildSections(metrics *types.SystemMetrics, temperatureUnit string) []section {
+	if metrics == nil {
+		return nil
+	}
+
+	var sections []section
+
+	if cpu := metrics.CPU; cpu != nil {
+		s := section{id: "cpu", label: "CPU", path: "cpu.usage_percent", percent: cpu.UsagePercent}
+		if cpu.Error != "" {
+			s.summary = "n/a"
+			s.details = []string{"Error: " + cpu.Error}
+		} else {
+			s.summary = fmt.Sprintf("%5.1f%%", cpu.UsagePercent)
+			if types.HasTemperature(cpu.Temperature) {
+				s.summary += "  " + types.FormatTemperature(cpu.Temperature, temperatureUnit)
+			}
+		}
+		sections = append(sections, s)
+	}
+
+	if memory := metrics.Memory; memory != nil {
+		s := section{id: "memory", label: "RAM", path: "memory.used_percent", percent: memory.UsedPercent}
+		if memory.Error != "" {
+			s.summary = "n/a"
+			s.details = []string{"Error: " + memory.Error}
+		} else {
+			s.summary = fmt.Sprintf("%5.1f%%", memory.UsedPercent)
+			s.details = []string{fmt.Sprintf("Used %s of %s", types.FormatGigabytes(memory.Used), types.FormatGigabytes(memory.Total))}
+		}
+		sections = append(sections, s)
+	}
+
+	if disk := metrics.Disk; disk != nil {
+		s := section{id: "disk", label: "HDD", path: "disk.used_percent", percent: disk.UsedPercent}
+		if disk.Error != "" {
+			s.summary = "n/a"
+			s.details = []string{"Error: " + disk.Error}
+		} else {
+			s.summary = fmt.Sprintf("%5.1f%%", disk.UsedPercent)
+			s.details = []string{fmt.Sprintf("Used %s of %s", types.FormatGigabytes(disk.Used), types.FormatGigabytes(disk.Total))}
+		}
+		for _, mount := range metrics.Mounts {
+			if mount.Error != "" {
+				s.details = append(s.details, fmt.Sprintf("%s  Error: %s", mount.Mount, mount.Error))
+			} else {
+				s.details = append(s.details, fmt.Sprintf("%s  %.1f%%, used %s of %s", mount.Mount, mount.UsedPercent, types.FormatGigabytes(mount.Used), types.FormatGigabytes(mount.Total)))
+			}
+		}
+		for _, drive := range metrics.Drives {
+			line := fmt.Sprintf("%s %s", drive.Device, drive.Model)
+			if types.HasTemperature(drive.Temperature) {
+				line += "  " + types.FormatTemperature(drive.Temperature, temperatureUnit)
+			}
+			for _, warning := range drive.Warnings {
+				line += "  ⚠ " + warning
+			}
+			s.details = append(s.details, line)
+		}
+		sections = append(sections, s)
+	}
+
+	for i, gpu := range metrics.GPUs {
+		s := section{
+			id:      fmt.Sprintf("gpu%d", i),
+			label:   fmt.Sprintf("GPU %d", i),
+			path:    fmt.Sprintf("gpus[%d].usage_percent", i),
+			percent: gpu.UsagePercent,
+			details: []string{gpu.Name},
+		}
+		if gpu.Error != "" {
+			s.summary = "n/a"
+			s.details = append(s.details, "Error: "+gpu.Error)
+		} else {
+			s.summary = fmt.Sprintf("%5.1f%%", gpu.UsagePercent)
+			if types.HasTemperature(gpu.Temperature) {
+				s.summary += "  " + types.FormatTemperature(gpu.Temperature, temperatureUnit)
+			}
+		}
+		sections = append(sections, s)
+	}
+
+	return sections
+}
+
+// renderSection draws a section as lines no wider than width. Selected
+// sections are highlighted and show their details and a full width sparkline.
+func renderSection(s section, history []float64, selected bool, width int) []string {
+	marker := "  "
+	if selected {
+		marker = "▶ "
+	}
+
+	label := padRight(s.label, labelWidth)
+	line := fmt.Sprintf("%s%s %s %s", marker, label, bar(s.percent, barWidth), s.summary)
+
+	if !selected {
+		// Use the remaining width for a short sparkline
+		used := utf8.RuneCountInString(line) + 2
+		if width-used > 0 {
+			line += "  " + sparkline(history, width-used)
+		}
+		return []string{truncate(line, width)}
+	}
+
+	lines := []string{"\x1b[1m" + truncate(line, width) + "\x1b[0m"}
+	indent := strings.Repeat(" ", len(marker))
+	for _, detail := range s.details {
+		lines = append(lines, truncate(indent+detail, width))
+	}
+	if width-len(indent) > 0 {
+		lines = append(lines, indent+sparkline(history, width-len(indent)))
+	}
+	return append(lines, "")
+}
+
+// bar draws a usage bar for a percentage
+func bar(percent float64, width int) string {
+	filled := int(clamp(percent, 0, 100) / 100 * float64(width))
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]"
+}
+
+// sparkline draws the last width values of a 0-100 series
+func sparkline(values []float64, width int) string {
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+
+	levels := []rune(sparkLevels)
+	var b strings.Builder
+	for _, value := range values {
+		level := int(clamp(value, 0, 100) / 100 * float64(len(levels)-1))
+		b.WriteRune(levels[level])
+	}
+	return b.String()
+}
+
+// clamp limits value to the [low, high] range
+func clamp(value, low, high float64) float64 {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
+
+// padRight pads text with spaces up to width runes
+func padRight(text string, width int) string {
+	if n := utf8.RuneCountInString(text); n < width {
+		return text + strings.Repeat(" ", width-n)
+	}
+	return text
+}
+
+// truncate cuts text to at most width runes
+func truncate(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
+	}
+	return string(runes[:width])
+}
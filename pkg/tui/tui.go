@@ -0,0 +1,241 @@
+package tui
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"p-monitor/pkg/config"
+	"p-monitor/pkg/monitor"
+	"p-monitor/pkg/types"
+
+	"golang.org/x/term"
+)
+
+const (
+	// resizeCheckInterval is how often the terminal size is checked for changes
+	resizeCheckInterval = 500 * time.Millisecond
+
+	// defaultWidth and defaultHeight are used when the terminal size is unknown
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+// key is a keyboard input relevant to the dashboard
+type key int
+
+const (
+	keyNone key = iota
+	keyUp
+	keyDown
+	keyQuit
+)
+
+// TUI is a full-screen terminal dashboard driven by the monitor subscription
+type TUI struct {
+	monitor  *monitor.Monitor
+	config   *config.Config
+	in       *os.File
+	out      *os.File
+	latest   *types.SystemMetrics
+	selected string
+	width    int
+	height   int
+}
+
+// New creates a new terminal dashboard
+func New(m *monitor.Monitor, cfg *config.Config) *TUI {
+	return &TUI{
+		monitor: m,
+		config:  cfg,
+		in:      os.Stdin,
+		out:     os.Stdout,
+	}
+}
+
+// Run takes over the terminal and renders every update until the user quits
+// or ctx is cancelled. The terminal state is always restored on return.
+func (t *TUI) Run(ctx context.Context) error {
+	fd := int(t.in.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("p-monitor top requires an interactive terminal")
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set terminal raw mode: %v", err)
+	}
+	defer term.Restore(fd, state)
+
+	// Switch to the alternate screen and hide the cursor
+	fmt.Fprint(t.out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(t.out, "\x1b[?25h\x1b[?1049l")
+
+	updates := t.monitor.Subscribe()
+	defer t.monitor.Unsubscribe(updates)
+
+	keys := make(chan key)
+	done := make(chan struct{})
+	defer close(done)
+	go t.readKeys(keys, done)
+
+	resize := time.NewTicker(resizeCheckInterval)
+	defer resize.Stop()
+
+	t.latest = t.monitor.GetMetrics()
+	t.updateSize()
+	t.render()
+
+	for {
+		select {
+		case metrics, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			t.latest = metrics
+			t.render()
+		case k := <-keys:
+			switch k {
+			case keyQuit:
+				return nil
+			case keyUp:
+				t.moveSelection(-1)
+			case keyDown:
+				t.moveSelection(1)
+			}
+			t.render()
+		case <-resize.C:
+			if t.updateSize() {
+				t.render()
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// readKeys decodes keyboard input into keys until stdin is closed or done
+func (t *TUI) readKeys(keys chan<- key, done <-chan struct{}) {
+	reader := bufio.NewReader(t.in)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			select {
+			case keys <- keyQuit:
+			case <-done:
+			}
+			return
+		}
+
+		k := keyNone
+		switch b {
+		case 'q', 'Q', 0x03: // Ctrl+C is not a signal in raw mode
+			k = keyQuit
+		case 'k', 'K':
+			k = keyUp
+		case 'j', 'J', '\t':
+			k = keyDown
+		case 0x1b:
+			// Arrow keys are sent as "ESC [ A" (up) and "ESC [ B" (down)
+			if next, err := reader.ReadByte(); err == nil && next == '[' {
+				if arrow, err := reader.ReadByte(); err == nil {
+					switch arrow {
+					case 'A':
+						k = keyUp
+					case 'B':
+						k = keyDown
+					}
+				}
+			}
+		}
+
+		if k == keyNone {
+			continue
+		}
+
+		select {
+		case keys <- k:
+		case <-done:
+			return
+		}
+	}
+}
+
+// updateSize reads the terminal size, reporting whether it changed
+func (t *TUI) updateSize() bool {
+	width, height, err := term.GetSize(int(t.out.Fd()))
+	if err != nil {
+		width, height = defaultWidth, defaultHeight
+	}
+
+	changed := width != t.width || height != t.height
+	t.width, t.height = width, height
+	return changed
+}
+
+// sparklineValues returns the recorded values of a section, oldest first
+func (t *TUI) sparklineValues(s section) []float64 {
+	points := t.monitor.History().Series(s.path, time.Time{}, time.Time{})
+	values := make([]float64, len(points))
+	for i, point := range points {
+		values[i] = point.Value
+	}
+	return values
+}
+
+// moveSelection moves the selected section up or down
+func (t *TUI) moveSelection(delta int) {
+	sections := buildSections(t.latest, t.config.Snapshot().TemperatureUnit)
+	if len(sections) == 0 {
+		return
+	}
+
+	index := 0
+	for i, s := range sections {
+		if s.id == t.selected {
+			index = i
+			break
+		}
+	}
+
+	index = (index + delta + len(sections)) % len(sections)
+	t.selected = sections[index].id
+}
+
+// render redraws the whole screen
+func (t *TUI) render() {
+	var b strings.Builder
+
+	// Move home and clear the screen
+	b.WriteString("\x1b[H\x1b[2J")
+
+	updated := "waiting for first collection"
+	if t.latest != nil {
+		updated = "updated " + t.latest.Updated.Format("15:04:05")
+	}
+	cfg := t.config.Snapshot()
+	header := fmt.Sprintf(" p-monitor top | %s | every %d %s | ↑/↓ select, q quit",
+		updated, cfg.UpdateInterval, cfg.TimeUnit)
+	lines := []string{"\x1b[7m" + truncate(padRight(header, t.width), t.width) + "\x1b[0m", ""}
+
+	sections := buildSections(t.latest, cfg.TemperatureUnit)
+	if t.selected == "" && len(sections) > 0 {
+		t.selected = sections[0].id
+	}
+
+	for _, s := range sections {
+		lines = append(lines, renderSection(s, t.sparklineValues(s), s.id == t.selected, t.width)...)
+	}
+
+	// Lines past the bottom of the terminal would scroll the screen
+	if len(lines) > t.height {
+		lines = lines[:t.height]
+	}
+
+	// Raw mode needs explicit carriage returns
+	b.WriteString(strings.Join(lines, "\r\n"))
+	fmt.Fprint(t.out, b.String())
+}
@@ -4,21 +4,50 @@ import "time"
 
 // SystemMetrics holds all system metrics
 type SystemMetrics struct {
-	Disk    *DiskMetrics   `json:"disk"`
-	Memory  *MemoryMetrics `json:"memory"`
-	CPU     *CPUMetrics    `json:"cpu"`
-	GPUs    []*GPUMetrics  `json:"gpus"`
-	Updated time.Time      `json:"updated"`
+	Disk       *DiskMetrics        `json:"disk"`
+	Mounts     []*DiskMetrics      `json:"mounts"` // Other writable filesystems than "/"
+	Drives     []*DriveHealth      `json:"drives"`
+	Memory     *MemoryMetrics      `json:"memory"`
+	CPU        *CPUMetrics         `json:"cpu"`
+	GPUs       []*GPUMetrics       `json:"gpus"`
+	Network    *NetworkMetrics     `json:"network"`
+	Services   []*ServiceMetrics   `json:"services"`
+	Slices     []*ServiceMetrics   `json:"slices"` // Top-level slices and scopes, including the usage of their services
+	Containers []*ContainerMetrics `json:"containers"`
+	Collectors []*CollectorStatus  `json:"collectors"`
+	Updated    time.Time           `json:"updated"`
+}
+
+// CollectorStatus holds the outcome of a single collector run
+type CollectorStatus struct {
+	Name     string  `json:"name"`
+	Duration float64 `json:"duration_seconds"`
+	Error    string  `json:"error,omitempty"`
 }
 
 // DiskMetrics holds disk usage information
 type DiskMetrics struct {
+	Mount       string  `json:"mount"`
 	Total       uint64  `json:"total"`
 	Used        uint64  `json:"used"`
 	UsedPercent float64 `json:"used_percent"`
 	Error       string  `json:"error,omitempty"`
 }
 
+// DriveHealth holds SMART health information of a physical drive
+type DriveHealth struct {
+	Device         string   `json:"device"` // Kernel name, e.g. "nvme0" or "sda"
+	Model          string   `json:"model"`
+	Type           string   `json:"type"` // "nvme" or "sata"
+	Temperature    float64  `json:"temperature"`
+	PercentageUsed float64  `json:"percentage_used"` // NVMe only
+	AvailableSpare float64  `json:"available_spare"` // NVMe only
+	MediaErrors    uint64   `json:"media_errors"`
+	Passed         *bool    `json:"passed"` // Overall SMART self-assessment, nil when unknown
+	Warnings       []string `json:"warnings,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
 // MemoryMetrics holds memory usage information
 type MemoryMetrics struct {
 	Total       uint64  `json:"total"`
@@ -29,9 +58,10 @@ type MemoryMetrics struct {
 
 // CPUMetrics holds CPU usage and temperature information
 type CPUMetrics struct {
-	UsagePercent float64 `json:"usage_percent"`
-	Temperature  float64 `json:"temperature"`
-	Error        string  `json:"error,omitempty"`
+	UsagePercent     float64   `json:"usage_percent"`
+	CoreUsagePercent []float64 `json:"core_usage_percent"` // Indexed by core number
+	Temperature      float64   `json:"temperature"`
+	Error            string    `json:"error,omitempty"`
 }
 
 // GPUMetrics holds GPU usage and temperature information
@@ -42,3 +72,38 @@ type GPUMetrics struct {
 	Temperature  float64 `json:"temperature"`
 	Error        string  `json:"error,omitempty"`
 }
+
+// NetworkMetrics holds the traffic of all network interfaces except loopback
+type NetworkMetrics struct {
+	RxBytes uint64  `json:"rx_bytes"`
+	TxBytes uint64  `json:"tx_bytes"`
+	RxRate  float64 `json:"rx_rate"` // Bytes per second
+	TxRate  float64 `json:"tx_rate"` // Bytes per second
+	Error   string  `json:"error,omitempty"`
+}
+
+// ServiceMetrics holds resource usage of a systemd service, or of a top-level
+// slice or scope cgroup
+type ServiceMetrics struct {
+	Name         string  `json:"name"` // Unit name, only for display as it may repeat
+	Path         string  `json:"path"` // Relative to the cgroup v2 mount, identifying the service
+	CPUPercent   float64 `json:"cpu_percent"`
+	MemoryBytes  uint64  `json:"memory_bytes"`
+	IOReadBytes  uint64  `json:"io_read_bytes"`
+	IOWriteBytes uint64  `json:"io_write_bytes"`
+	IOReadRate   float64 `json:"io_read_rate"`  // Bytes per second
+	IOWriteRate  float64 `json:"io_write_rate"` // Bytes per second
+}
+
+// ContainerMetrics holds resource usage of a Docker or Podman container
+type ContainerMetrics struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Runtime     string  `json:"runtime"` // "docker" or "podman"
+	CPUPercent  float64 `json:"cpu_percent"`
+	MemoryBytes uint64  `json:"memory_bytes"`
+	NetRxBytes  uint64  `json:"net_rx_bytes"`
+	NetTxBytes  uint64  `json:"net_tx_bytes"`
+	NetRxRate   float64 `json:"net_rx_rate"` // Bytes per second
+	NetTxRate   float64 `json:"net_tx_rate"` // Bytes per second
+}
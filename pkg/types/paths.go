@@ -0,0 +1,111 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Values flattens every numeric metric into a map keyed by metric path. Paths
+// use the JSON field names, with list items identified by their index or
+// name, e.g. "cpu.usage_percent", "cpu.cores[3].usage_percent",
+// "gpus[0].temperature", "mounts[/home].used", "drives[nvme0].temperature" or
+// "containers[web].memory_bytes". Services and slices are identified by their cgroup
+// path, e.g. "services[system.slice/dbus.service].memory_bytes", as unit names
+// repeat across slices. Collectors that failed are left out.
+func (m *SystemMetrics) Values() map[string]float64 {
+	values := make(map[string]float64)
+
+	if disk := m.Disk; disk != nil && disk.Error == "" {
+		values["disk.total"] = float64(disk.Total)
+		values["disk.used"] = float64(disk.Used)
+		values["disk.used_percent"] = disk.UsedPercent
+	}
+
+	for _, mount := range m.Mounts {
+		if mount.Error != "" {
+			continue
+		}
+		prefix := itemPath("mounts", mount.Mount)
+		values[prefix+".total"] = float64(mount.Total)
+		values[prefix+".used"] = float64(mount.Used)
+		values[prefix+".used_percent"] = mount.UsedPercent
+	}
+
+	for _, drive := range m.Drives {
+		prefix := itemPath("drives", drive.Device)
+		if HasTemperature(drive.Temperature) {
+			values[prefix+".temperature"] = drive.Temperature
+		}
+		if drive.Error != "" {
+			continue
+		}
+		values[prefix+".percentage_used"] = drive.PercentageUsed
+		values[prefix+".available_spare"] = drive.AvailableSpare
+		values[prefix+".media_errors"] = float64(drive.MediaErrors)
+	}
+
+	if memory := m.Memory; memory != nil && memory.Error == "" {
+		values["memory.total"] = float64(memory.Total)
+		values["memory.used"] = float64(memory.Used)
+		values["memory.used_percent"] = memory.UsedPercent
+	}
+
+	if cpu := m.CPU; cpu != nil && cpu.Error == "" {
+		values["cpu.usage_percent"] = cpu.UsagePercent
+		for core, usage := range cpu.CoreUsagePercent {
+			values[itemPath("cpu.cores", strconv.Itoa(core))+".usage_percent"] = usage
+		}
+		if HasTemperature(cpu.Temperature) {
+			values["cpu.temperature"] = cpu.Temperature
+		}
+	}
+
+	for i, gpu := range m.GPUs {
+		if gpu.Error != "" {
+			continue
+		}
+		prefix := itemPath("gpus", strconv.Itoa(i))
+		values[prefix+".usage_percent"] = gpu.UsagePercent
+		if HasTemperature(gpu.Temperature) {
+			values[prefix+".temperature"] = gpu.Temperature
+		}
+	}
+
+	if network := m.Network; network != nil && network.Error == "" {
+		values["network.rx_bytes"] = float64(network.RxBytes)
+		values["network.tx_bytes"] = float64(network.TxBytes)
+		values["network.rx_rate"] = network.RxRate
+		values["network.tx_rate"] = network.TxRate
+	}
+
+	for _, service := range m.Services {
+		prefix := itemPath("services", service.Path)
+		values[prefix+".cpu_percent"] = service.CPUPercent
+		values[prefix+".memory_bytes"] = float64(service.MemoryBytes)
+		values[prefix+".io_read_rate"] = service.IOReadRate
+		values[prefix+".io_write_rate"] = service.IOWriteRate
+	}
+
+	for _, slice := range m.Slices {
+		prefix := itemPath("slices", slice.Path)
+		values[prefix+".cpu_percent"] = slice.CPUPercent
+		values[prefix+".memory_bytes"] = float64(slice.MemoryBytes)
+		values[prefix+".io_read_rate"] = slice.IOReadRate
+		values[prefix+".io_write_rate"] = slice.IOWriteRate
+	}
+
+	for _, container := range m.Containers {
+		prefix := itemPath("containers", container.Name)
+		values[prefix+".cpu_percent"] = container.CPUPercent
+		values[prefix+".memory_bytes"] = float64(container.MemoryBytes)
+		values[prefix+".net_rx_rate"] = container.NetRxRate
+		values[prefix+".net_tx_rate"] = container.NetTxRate
+	}
+
+	return values
+}
+
+// itemPath returns the path of a list item, e.g. "gpus[0]"
+func itemPath(list, key string) string {
+	return fmt.Sprintf("%s[%s]", list, key)
+}
@@ -0,0 +1,98 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Temperature units, as set in the temperature_unit configuration. Metrics
+// always hold Celsius, converted only when formatted.
+const (
+	Celsius    = "celsius"
+	Fahrenheit = "fahrenheit"
+)
+
+// Binary byte units
+const (
+	KB = 1024
+	MB = 1024 * KB
+	GB = 1024 * MB
+	TB = 1024 * GB
+)
+
+// ConvertTemperature converts a Celsius temperature to the given unit
+func ConvertTemperature(celsius float64, unit string) float64 {
+	if unit == Fahrenheit {
+		return celsius*9/5 + 32
+	}
+	return celsius
+}
+
+// TemperatureSymbol returns the symbol of a temperature unit, "°C" or "°F"
+func TemperatureSymbol(unit string) string {
+	if unit == Fahrenheit {
+		return "°F"
+	}
+	return "°C"
+}
+
+// FormatTemperature formats a Celsius temperature in the given unit with one
+// decimal, e.g. "45.2°C" or "113.4°F"
+func FormatTemperature(celsius float64, unit string) string {
+	return fmt.Sprintf("%.1f%s", ConvertTemperature(celsius, unit), TemperatureSymbol(unit))
+}
+
+// HasTemperature reports whether a Celsius temperature was read from a
+// sensor. Collectors leave it at 0 when there is none.
+func HasTemperature(celsius float64) bool {
+	return celsius > 0
+}
+
+// FormatReading formats a Celsius temperature in the given unit with the given
+// number of decimals, or "n/a" when no sensor was read, e.g. "45°C" for 0
+// decimals
+func FormatReading(celsius float64, unit string, decimals int) string {
+	if !HasTemperature(celsius) {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.*f%s", decimals, ConvertTemperature(celsius, unit), TemperatureSymbol(unit))
+}
+
+// FormatBytes formats a byte amount using the largest fitting binary unit,
+// e.g. "512.0B" or "1.5GB"
+func FormatBytes(bytes float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+
+	return fmt.Sprintf("%.1f%s", bytes, units[unit])
+}
+
+// FormatGigabytes formats a byte count in gigabytes, e.g. "217.9GB"
+func FormatGigabytes(bytes uint64) string {
+	return fmt.Sprintf("%.1fGB", float64(bytes)/GB)
+}
+
+// FormatValue formats the value of a metric path (see Values) in its unit:
+// temperatures in the given temperature unit, byte amounts and rates in the
+// largest fitting unit, and percentages with a percent sign
+func FormatValue(path string, value float64, temperatureUnit string) string {
+	name := path[strings.LastIndex(path, ".")+1:]
+
+	switch {
+	case name == "temperature":
+		return FormatTemperature(value, temperatureUnit)
+	case name == "total" || name == "used" || strings.HasSuffix(name, "_bytes"):
+		return FormatBytes(value)
+	case strings.HasSuffix(name, "_rate"):
+		return FormatBytes(value) + "/s"
+	case strings.HasSuffix(name, "percent") || name == "percentage_used" || name == "available_spare":
+		return fmt.Sprintf("%.1f%%", value)
+	default:
+		return fmt.Sprintf("%g", value)
+	}
+}
@@ -0,0 +1,168 @@
+package types
+
+import "testing"
+
+func TestConvertTemperature(t *testing.T) {
+	tests := []struct {
+		celsius float64
+		unit    string
+		want    float64
+	}{
+		{celsius: 0, unit: Celsius, want: 0},
+		{celsius: 45.5, unit: Celsius, want: 45.5},
+		{celsius: -40, unit: Celsius, want: -40},
+		{celsius: 0, unit: Fahrenheit, want: 32},
+		{celsius: 100, unit: Fahrenheit, want: 212},
+		{celsius: -40, unit: Fahrenheit, want: -40},
+		{celsius: 37, unit: Fahrenheit, want: 98.6},
+		{celsius: 45, unit: "", want: 45},       // Unset defaults to Celsius
+		{celsius: 45, unit: "kelvin", want: 45}, // Unknown units too
+	}
+
+	for _, tt := range tests {
+		got := ConvertTemperature(tt.celsius, tt.unit)
+		if diff := got - tt.want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("ConvertTemperature(%v, %q) = %v, want %v", tt.celsius, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestTemperatureSymbol(t *testing.T) {
+	tests := []struct {
+		unit string
+		want string
+	}{
+		{unit: Celsius, want: "°C"},
+		{unit: Fahrenheit, want: "°F"},
+		{unit: "", want: "°C"},
+		{unit: "kelvin", want: "°C"},
+	}
+
+	for _, tt := range tests {
+		if got := TemperatureSymbol(tt.unit); got != tt.want {
+			t.Errorf("TemperatureSymbol(%q) = %q, want %q", tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestFormatTemperature(t *testing.T) {
+	tests := []struct {
+		celsius float64
+		unit    string
+		want    string
+	}{
+		{celsius: 45.2, unit: Celsius, want: "45.2°C"},
+		{celsius: 45.25, unit: Celsius, want: "45.2°C"},
+		{celsius: 0, unit: Celsius, want: "0.0°C"},
+		{celsius: -5.5, unit: Celsius, want: "-5.5°C"},
+		{celsius: 45.2, unit: Fahrenheit, want: "113.4°F"},
+		{celsius: 0, unit: Fahrenheit, want: "32.0°F"},
+		{celsius: 100, unit: Fahrenheit, want: "212.0°F"},
+		{celsius: 45.2, unit: "", want: "45.2°C"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatTemperature(tt.celsius, tt.unit); got != tt.want {
+			t.Errorf("FormatTemperature(%v, %q) = %q, want %q", tt.celsius, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestFormatReading(t *testing.T) {
+	tests := []struct {
+		celsius  float64
+		unit     string
+		decimals int
+		want     string
+	}{
+		{celsius: 45.24, unit: Celsius, decimals: 1, want: "45.2°C"},
+		{celsius: 45.24, unit: Celsius, decimals: 0, want: "45°C"},
+		{celsius: 45, unit: Fahrenheit, decimals: 0, want: "113°F"},
+		{celsius: 0.5, unit: Celsius, decimals: 1, want: "0.5°C"},
+		{celsius: 0, unit: Celsius, decimals: 1, want: "n/a"}, // No sensor
+		{celsius: 0, unit: Fahrenheit, decimals: 0, want: "n/a"},
+		{celsius: -5, unit: Celsius, decimals: 1, want: "n/a"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatReading(tt.celsius, tt.unit, tt.decimals); got != tt.want {
+			t.Errorf("FormatReading(%v, %q, %d) = %q, want %q", tt.celsius, tt.unit, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		bytes float64
+		want  string
+	}{
+		{bytes: 0, want: "0.0B"},
+		{bytes: 512, want: "512.0B"},
+		{bytes: 1023, want: "1023.0B"},
+		{bytes: KB, want: "1.0KB"},
+		{bytes: 1536, want: "1.5KB"},
+		{bytes: MB - 1, want: "1024.0KB"},
+		{bytes: MB, want: "1.0MB"},
+		{bytes: 1.5 * GB, want: "1.5GB"},
+		{bytes: TB, want: "1.0TB"},
+		{bytes: 2048 * TB, want: "2048.0TB"}, // TB is the largest unit
+	}
+
+	for _, tt := range tests {
+		if got := FormatBytes(tt.bytes); got != tt.want {
+			t.Errorf("FormatBytes(%v) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestFormatGigabytes(t *testing.T) {
+	tests := []struct {
+		bytes uint64
+		want  string
+	}{
+		{bytes: 0, want: "0.0GB"},
+		{bytes: MB, want: "0.0GB"},
+		{bytes: 512 * MB, want: "0.5GB"},
+		{bytes: GB, want: "1.0GB"},
+		{bytes: 233965772800, want: "217.9GB"},
+		{bytes: 2 * TB, want: "2048.0GB"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatGigabytes(tt.bytes); got != tt.want {
+			t.Errorf("FormatGigabytes(%d) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		path  string
+		value float64
+		unit  string
+		want  string
+	}{
+		{path: "cpu.temperature", value: 45.2, unit: Celsius, want: "45.2°C"},
+		{path: "gpus[0].temperature", value: 45.2, unit: Fahrenheit, want: "113.4°F"},
+		{path: "drives[nvme0].temperature", value: 38, unit: "", want: "38.0°C"},
+		{path: "disk.total", value: 1.5 * GB, want: "1.5GB"},
+		{path: "mounts[/home].used", value: 512, want: "512.0B"},
+		{path: "services[user.slice].memory_bytes", value: 2 * MB, want: "2.0MB"},
+		{path: "network.rx_bytes", value: 1536, want: "1.5KB"},
+		{path: "network.tx_rate", value: 1536, want: "1.5KB/s"},
+		{path: "containers[web].net_rx_rate", value: 0, want: "0.0B/s"},
+		{path: "cpu.usage_percent", value: 12.345, want: "12.3%"},
+		{path: "cpu.cores[3].usage_percent", value: 100, want: "100.0%"},
+		{path: "services[nginx.service].cpu_percent", value: 0.5, want: "0.5%"},
+		{path: "drives[nvme0].percentage_used", value: 3, want: "3.0%"},
+		{path: "drives[nvme0].available_spare", value: 100, want: "100.0%"},
+		{path: "drives[sda].media_errors", value: 2, want: "2"},
+		{path: "unknown", value: 0.25, want: "0.25"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatValue(tt.path, tt.value, tt.unit); got != tt.want {
+			t.Errorf("FormatValue(%q, %v, %q) = %q, want %q", tt.path, tt.value, tt.unit, got, tt.want)
+		}
+	}
+}